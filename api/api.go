@@ -3,7 +3,8 @@ package api
 import (
 	"BastetSoftware/backend/database"
 	"bytes"
-	"database/sql"
+	"context"
+	"reflect"
 
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -14,6 +15,8 @@ const (
 	EPassWrong
 	ENotLoggedIn
 	EAccessDenied
+	ETooManyRequests // rate limit exceeded
+	ETooLarge        // request body too large
 
 	EArgsInval uint8 = 253 // invalid arguments
 	ENoFun     uint8 = 254 // function does not exist
@@ -25,30 +28,93 @@ const (
  */
 
 type Request struct {
+	Func uint8              // function number
+	Args msgpack.RawMessage // function arguments
+	Refs map[string]string  `msgpack:",omitempty" json:",omitempty"` // arguments set to fields of earlier responses, e.g. "Object": "0.Id"
+}
+
+// Frame: request sent over the WebSocket transport
+type Frame struct {
+	Id   uint32 // chosen by the client, echoed in the response
 	Func uint8
-	Args []byte
+	Args msgpack.RawMessage
+}
+
+// FrameResp: response sent over the WebSocket transport
+type FrameResp struct {
+	Id   uint32
+	Resp interface{}
 }
 
 // Response: basic response
 type Response struct {
+	Code  uint8
+	Error *ErrorDetail `msgpack:",omitempty" json:",omitempty"` // set if Code is not 0
+}
+
+/* FBatch */
+
+type ArgsFBatch struct {
+	Requests []Request `validate:"max=64"`
+	Atomic   bool      // run in one transaction, stop and roll back on the first error
+}
+
+type RespFBatch struct {
+	Code      uint8
+	Error     *ErrorDetail  `msgpack:",omitempty" json:",omitempty"` // of the failed request of an atomic batch
+	Responses []interface{} // responses in the order of requests
+}
+
+/* FDescribe */
+
+// FieldDesc: description of a struct field
+type FieldDesc struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Rules    string      // validation rules, see validate.go
+	Fields   []FieldDesc // fields of a struct type or of the struct elements of an array
+}
+
+// ErrorDesc: description of an error code
+type ErrorDesc struct {
+	Name string
 	Code uint8
+	Key  string // message key of ErrorDetail
+}
+
+// FunctionDesc: description of an API function
+type FunctionDesc struct {
+	Name   string
+	Number uint8
+	Auth   bool        // requires a session token
+	Args   []FieldDesc // nil if the function takes no arguments
+	Resp   []FieldDesc
+	Errors []string
+}
+
+type RespFDescribe struct {
+	Code         uint8
+	Errors       []ErrorDesc
+	CommonErrors []string // errors any function can return
+	Functions    []FunctionDesc
 }
 
 /* FUserCreate */
 
 type ArgsFUserCreate struct {
-	Login      string
-	Password   string
-	FirstName  string
-	LastName   string
+	Login      string `validate:"required"`
+	Password   string `validate:"min=8"`
+	FirstName  string `validate:"required"`
+	LastName   string `validate:"required"`
 	Patronymic string
 }
 
 /* FLogIn */
 
 type ArgsFLogIn struct {
-	Login    string
-	Password string
+	Login    string `validate:"required"`
+	Password string `validate:"required"`
 }
 
 type RespFLogIn struct {
@@ -81,10 +147,10 @@ type RespFUserInfo struct {
 
 type ArgsFUserEdit struct {
 	Token      string
-	Login      *string
-	Password   *string
-	FirstName  *string
-	LastName   *string
+	Login      *string `validate:"required"`
+	Password   *string `validate:"min=8"`
+	FirstName  *string `validate:"required"`
+	LastName   *string `validate:"required"`
 	Patronymic *string
 }
 
@@ -114,7 +180,7 @@ type RespFUserListGroups struct {
 
 type ArgsFGroupCreateRemove struct {
 	Token string
-	Name  string
+	Name  string `validate:"required"`
 }
 
 /* FGroupAddRemoveUser */
@@ -144,18 +210,18 @@ type RespFGroupGetInfo struct {
 
 type ArgsFStructCreate struct {
 	Token       string
-	Name        string
+	Name        string `validate:"required"`
 	Description string
 	District    string
 	Region      string
 	Address     string
 	Type        string
 	State       string
-	Area        int32
+	Area        int32 `validate:"min=0"`
 	Owner       string
 	Actual_user string
 	Gid         int64
-	Permissions int8
+	Permissions int8 `validate:"min=0,max=63"`
 }
 
 type RespFStructCreate struct {
@@ -185,6 +251,23 @@ type RespFStructInfo struct {
 	Permissions int8
 }
 
+// RespFStructInfoV2: RespFStructInfo with Code
+type RespFStructInfoV2 struct {
+	Code        uint8
+	Name        string
+	Description string
+	District    string
+	Region      string
+	Address     string
+	Type        string
+	State       string
+	Area        int32
+	Owner       string
+	Actual_user string
+	Gid         int64
+	Permissions int8
+}
+
 type ArgsFStructFind struct {
 	Token       string
 	Name        string
@@ -216,31 +299,31 @@ type ArgsFDeleteStruct struct {
 type ArgsFStructEdit struct {
 	Token       string
 	Id          int64
-	Name        *string
+	Name        *string `validate:"required"`
 	Description *string
 	District    *string
 	Region      *string
 	Address     *string
 	Type        *string
 	State       *string
-	Area        *int32
+	Area        *int32 `validate:"min=0"`
 	Owner       *string
 	Actual_user *string
-	Permissions *int8
+	Permissions *int8 `validate:"min=0,max=63"`
 }
 
 /* FTaskCreate */
 
 type ArgsFTaskCreate struct {
 	Token       string
-	Name        string
+	Name        string `validate:"required"`
 	Description string
 	Deadline    int64
 	Status      string
 	Object      int64
 	Maintainer  int64
 	Gid         int64
-	Permissions uint8
+	Permissions uint8 `validate:"max=63"`
 }
 
 type RespFTaskCreate struct {
@@ -287,8 +370,8 @@ type ArgsFTaskSearch struct {
 	Maintainer   *int64
 	Gid          *int64
 
-	Limit  int16
-	Offset int16
+	Limit  int16 `validate:"min=1,max=1000"`
+	Offset int16 `validate:"min=0"`
 }
 
 type RespFTaskSearch struct {
@@ -300,7 +383,7 @@ type RespFTaskSearch struct {
  * Common
  */
 
-type RequestHandler func(r []byte) (interface{}, error)
+type RequestHandler func(ctx context.Context, db database.Store, r []byte) (interface{}, error)
 
 func CustomUnmarshal(data []byte, v interface{}) error {
 	dec := msgpack.GetDecoder()
@@ -314,4 +397,34 @@ func CustomUnmarshal(data []byte, v interface{}) error {
 	return err
 }
 
-var Db *sql.DB // Db reference
+// ResponseCode: Code field of a response, 0 if it has none
+func ResponseCode(v interface{}) uint8 {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return 0
+	}
+
+	code := rv.FieldByName("Code")
+	if !code.IsValid() || code.Kind() != reflect.Uint8 {
+		return 0
+	}
+
+	return uint8(code.Uint())
+}
+
+// ResponseError: Error field of a response, nil if it has none
+func ResponseError(v interface{}) *ErrorDetail {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	detail := rv.FieldByName("Error")
+	if !detail.IsValid() || detail.Type() != errorDetailType {
+		return nil
+	}
+
+	return detail.Interface().(*ErrorDetail)
+}
+
+var Db database.Store // Db reference
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testPassword = "test-password"
+	testToken    = "test-token-of-ivanov"  // session of ivanov
+	testToken2   = "test-token-of-petrova" // session of petrova
+)
+
+// newTestStore: memory store with the users ivanov (manages groups) and
+// petrova, the group engineers and sessions of both
+func newTestStore(t *testing.T) *database.MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	db := database.NewMemoryStore()
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := []database.UserInfo{
+		{Login: "ivanov", PassHash: passHash, FirstName: "Ivan", LastName: "Ivanov", ManagesGroups: true},
+		{Login: "petrova", PassHash: passHash, FirstName: "Anna", LastName: "Petrova"},
+	}
+	for i := range users {
+		if users[i].Id, err = db.RegisterUser(ctx, &users[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = db.CreateGroup(ctx, "engineers"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, token := range []string{testToken, testToken2} {
+		session := database.Session{
+			Token:      []byte(token),
+			ExpiryDate: time.Now().Add(time.Hour).Unix(),
+			User:       users[i].Id,
+		}
+		if err = db.AddSession(ctx, &session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+// call: call function name of v with args and decode the response into resp
+func call(t *testing.T, db database.Store, v *Version, name string, args interface{}, resp interface{}) {
+	t.Helper()
+	f := v.Lookup(name)
+	if f == nil {
+		t.Fatalf("no function %s in %s", name, v.Name)
+	}
+
+	r, err := msgpack.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _ := f.Handler(WithVersion(context.Background(), v), db, r)
+
+	// as the client receives it
+	b, err := msgpack.Marshal(WithErrorDetail(response))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = msgpack.Unmarshal(b, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// funcNumber: number of function name in v
+func funcNumber(t *testing.T, v *Version, name string) uint8 {
+	t.Helper()
+	for i, f := range v.Functions {
+		if f.Name == name {
+			return uint8(i)
+		}
+	}
+	t.Fatalf("no function %s in %s", name, v.Name)
+	return 0
+}
+
+// marshal: msgpack encoding of v
+func marshal(t *testing.T, v interface{}) msgpack.RawMessage {
+	t.Helper()
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Caller: logged-in user calling an API function
+type Caller struct {
+	Session *database.Session
+	User    *database.UserInfo
+}
+
+// AuthRequestHandler: API function for logged-in users only
+type AuthRequestHandler func(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error)
+
+// CallInfo: details of a call found out by the api package, for logging
+type CallInfo struct {
+	Uid int64 // caller, 0 if not logged in
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo: context of a call that fills info in
+func WithCallInfo(ctx context.Context, info *CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// verifiedSession: result of a session lookup done before the function runs
+type verifiedSession struct {
+	token   string
+	session *database.Session // nil if the token has no open session
+}
+
+type sessionKey struct{}
+
+// WithSession: context of a call whose token was already looked up, e.g. by
+// a middleware, so that the function does not look it up again; session is
+// nil if the token has no open session
+func WithSession(ctx context.Context, token string, session *database.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, verifiedSession{token: token, session: session})
+}
+
+// verifySession: open session of token, looked up once per call
+func verifySession(ctx context.Context, db database.Store, token string) (*database.Session, error) {
+	if v, ok := ctx.Value(sessionKey{}).(verifiedSession); ok && v.token == token {
+		if v.session == nil {
+			return nil, database.ErrNotLoggedIn
+		}
+		return v.session, nil
+	}
+	return db.VerifySession(ctx, []byte(token))
+}
+
+// argsToken: session token of any arguments struct
+type argsToken struct {
+	Token string
+}
+
+// authenticated: verify the session token of the arguments, check them against
+// the rules of args and pass the caller to handler
+func authenticated(args interface{}, handler AuthRequestHandler) RequestHandler {
+	return func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		// other fields are parsed by the handler
+		var token argsToken
+		err := msgpack.Unmarshal(r, &token)
+		if err != nil {
+			return Response{Code: EArgsInval}, err
+		}
+
+		session, err := verifySession(ctx, db, token.Token)
+		switch err {
+		case nil:
+			break
+		case database.ErrNotLoggedIn:
+			return Response{Code: ENotLoggedIn}, nil
+		default:
+			return Response{Code: EUnknown}, err
+		}
+
+		user, err := db.GetUserInfo(ctx, session.User)
+		switch err {
+		case nil:
+			break
+		case database.ErrNoUser:
+			return Response{Code: ENotLoggedIn}, nil
+		default:
+			return Response{Code: EUnknown}, err
+		}
+
+		if info, ok := ctx.Value(callInfoKey{}).(*CallInfo); ok {
+			info.Uid = user.Id
+		}
+
+		if err := validateArgs(args, r); err != nil {
+			return argsResponse(err)
+		}
+
+		return handler(ctx, db, &Caller{Session: session, User: user}, r)
+	}
+}
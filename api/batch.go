@@ -0,0 +1,155 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// resultRef: reference to a field of an earlier response of the batch,
+// e.g. "0.Id"
+var resultRef = regexp.MustCompile(`^([0-9]+)((\.[A-Za-z0-9_]+)+)$`)
+
+// errBadRef: a reference to a missing or failed response or to a missing field
+var errBadRef = errors.New("invalid reference")
+
+// refValue: value of the field of an earlier response ref refers to
+func refValue(responses []interface{}, ref string) (interface{}, error) {
+	m := resultRef.FindStringSubmatch(ref)
+	if m == nil {
+		return nil, errBadRef
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n >= len(responses) || ResponseCode(responses[n]) != 0 {
+		return nil, errBadRef
+	}
+
+	// the response as it is sent to the client
+	b, err := msgpack.Marshal(responses[n])
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err = msgpack.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(m[2][1:], ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[name]; !ok {
+				return nil, errBadRef
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i >= len(node) {
+				return nil, errBadRef
+			}
+			v = node[i]
+		default:
+			return nil, errBadRef
+		}
+	}
+
+	return v, nil
+}
+
+// withRefs: args of req with the arguments named in req.Refs set to the
+// values they refer to; the values of Args are never interpreted
+func withRefs(req *Request, responses []interface{}) (msgpack.RawMessage, interface{}) {
+	if len(req.Refs) == 0 {
+		return req.Args, nil
+	}
+
+	args := make(map[string]interface{})
+	if len(req.Args) > 0 {
+		if err := msgpack.Unmarshal(req.Args, &args); err != nil {
+			// not an object, reported by the function
+			return req.Args, nil
+		}
+	}
+
+	for name, ref := range req.Refs {
+		value, err := refValue(responses, ref)
+		if err != nil {
+			return nil, Response{
+				Code:  EArgsInval,
+				Error: &ErrorDetail{Key: "args.reference", Message: "reference to a missing or failed response", Field: "Refs." + name},
+			}
+		}
+		args[name] = value
+	}
+
+	b, err := msgpack.Marshal(args)
+	if err != nil {
+		return nil, Response{Code: EUnknown}
+	}
+	return b, nil
+}
+
+// runBatch: run requests one by one; if stopOnError is set, stop after
+// the first response with a non-zero code and return that code
+func runBatch(ctx context.Context, db database.Store, requests []Request, stopOnError bool) ([]interface{}, uint8) {
+	responses := make([]interface{}, 0, len(requests))
+	for _, req := range requests {
+		var response interface{}
+		f := VersionOf(ctx).LookupF(req.Func)
+		switch {
+		case f == nil:
+			response = Response{Code: ENoFun}
+		case f.Name == "batch":
+			// no nested batches
+			response = Response{
+				Code:  EArgsInval,
+				Error: &ErrorDetail{Key: "args.nested_batch", Message: "batch cannot be called in a batch", Field: "Func"},
+			}
+		default:
+			args, failed := withRefs(&req, responses)
+			if failed != nil {
+				response = failed
+				break
+			}
+			// errors are logged by the middleware of f
+			response, _ = f.Handler(ctx, db, args)
+		}
+		response = WithErrorDetail(response)
+		responses = append(responses, response)
+
+		if code := ResponseCode(response); stopOnError && code != 0 {
+			return responses, code
+		}
+	}
+
+	return responses, 0
+}
+
+// HandleFBatch: run several API functions in one call
+func HandleFBatch(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+	// parse args
+	var args ArgsFBatch
+	err := CustomUnmarshal(r, &args)
+	if err != nil {
+		return argsError(r, &args), err
+	}
+
+	if !args.Atomic {
+		responses, _ := runBatch(ctx, db, args.Requests, false)
+		return RespFBatch{Code: 0, Responses: responses}, nil
+	}
+
+	// atomic batch: all requests share one transaction
+	return atomically(ctx, db, func(tx database.Store) (interface{}, error) {
+		responses, code := runBatch(ctx, tx, args.Requests, true)
+		if code != 0 {
+			failed := ResponseError(responses[len(responses)-1])
+			return RespFBatch{Code: code, Error: failed, Responses: responses}, nil
+		}
+		return RespFBatch{Code: 0, Responses: responses}, nil
+	})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestBatchRefs(t *testing.T) {
+	db := newTestStore(t)
+	fn := func(name string) uint8 { return funcNumber(t, V2, name) }
+
+	var resp struct {
+		Code      uint8
+		Responses []struct {
+			Code        uint8
+			Error       *ErrorDetail
+			Id          int64
+			Object      int64
+			Description string
+		}
+	}
+	call(t, db, V2, "batch", ArgsFBatch{Atomic: true, Requests: []Request{
+		{Func: fn("object_create"), Args: marshal(t, ArgsFStructCreate{Token: testToken, Name: "Boiler house", Gid: 1})},
+		{
+			Func: fn("task_create"),
+			// strings of Args are never references
+			Args: marshal(t, ArgsFTaskCreate{Token: testToken, Name: "Check", Description: "$0.Code", Gid: 1}),
+			Refs: map[string]string{"Object": "0.Id"},
+		},
+		{Func: fn("task_get_info"), Args: marshal(t, ArgsFTaskGetInfo{Token: testToken}), Refs: map[string]string{"Id": "1.Id"}},
+	}}, &resp)
+
+	if resp.Code != 0 || len(resp.Responses) != 3 {
+		t.Fatalf("batch: got %+v", resp)
+	}
+	object, task := resp.Responses[0].Id, resp.Responses[2]
+	if task.Object != object || task.Description != "$0.Code" {
+		t.Errorf("task_get_info: got %+v, want Object %d and Description $0.Code", task, object)
+	}
+
+	for _, ref := range []string{"3.Id", "0.Missing", "0", "$0.Id", "0.Id.x", "x.Id"} {
+		call(t, db, V2, "batch", ArgsFBatch{Requests: []Request{
+			{Func: fn("object_create"), Args: marshal(t, ArgsFStructCreate{Token: testToken, Name: "Shed", Gid: 1})},
+			{Func: fn("task_create"), Args: marshal(t, ArgsFTaskCreate{Token: testToken, Name: "Paint", Gid: 1}), Refs: map[string]string{"Object": ref}},
+		}}, &resp)
+		failed := resp.Responses[1]
+		if failed.Code != EArgsInval || failed.Error == nil || failed.Error.Key != "args.reference" || failed.Error.Field != "Refs.Object" {
+			t.Errorf("reference %q: got %+v", ref, failed)
+		}
+	}
+
+	// to a failed response
+	call(t, db, V2, "batch", ArgsFBatch{Requests: []Request{
+		{Func: fn("object_create"), Args: marshal(t, ArgsFStructCreate{Token: "wrong", Name: "Shed", Gid: 1})},
+		{Func: fn("task_create"), Args: marshal(t, ArgsFTaskCreate{Token: testToken, Name: "Paint", Gid: 1}), Refs: map[string]string{"Object": "0.Code"}},
+	}}, &resp)
+	if failed := resp.Responses[1]; failed.Code != EArgsInval || failed.Error.Key != "args.reference" {
+		t.Errorf("reference to a failed response: got %+v", failed)
+	}
+}
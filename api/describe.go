@@ -0,0 +1,115 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var rawMessageType = reflect.TypeOf(msgpack.RawMessage{})
+
+// describeType: type name of t, whether it can be null, and its fields
+// if it is a struct or an array of structs
+func describeType(t reflect.Type, seen map[reflect.Type]bool) (string, bool, []FieldDesc) {
+	switch {
+	case t == rawMessageType:
+		return "object", true, nil
+	case t.Kind() == reflect.Pointer:
+		name, _, fields := describeType(t.Elem(), seen)
+		return name, true, fields
+	case t.Kind() == reflect.Interface:
+		return "any", true, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "bytes", true, nil
+	case t.Kind() == reflect.Slice:
+		name, _, fields := describeType(t.Elem(), seen)
+		return "[]" + name, true, fields
+	case t.Kind() == reflect.Struct:
+		return t.Name(), false, describeFields(t, seen)
+	default:
+		return t.Kind().String(), false, nil
+	}
+}
+
+// describeFields: describe exported fields of a struct type
+func describeFields(t reflect.Type, seen map[reflect.Type]bool) []FieldDesc {
+	if seen[t] {
+		// recursive type, described above
+		return nil
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	fields := make([]FieldDesc, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		var desc FieldDesc
+		desc.Name = f.Name
+		desc.Rules = f.Tag.Get("validate")
+		desc.Type, desc.Nullable, desc.Fields = describeType(f.Type, seen)
+		fields = append(fields, desc)
+	}
+
+	return fields
+}
+
+func describeValue(v interface{}) []FieldDesc {
+	if v == nil {
+		return nil
+	}
+	return describeFields(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+// errorNames: names of the error codes
+func errorNames(codes []uint8) []string {
+	names := make([]string, len(codes))
+	for i, code := range codes {
+		names[i] = ErrorNames[code]
+	}
+	return names
+}
+
+// Describe: description of the functions of v and of the error codes,
+// built from the types in v.Functions
+func (v *Version) Describe() RespFDescribe {
+	v.describe.Do(func() {
+		description := &v.description
+		description.Errors = make([]ErrorDesc, 0, len(ErrorNames))
+		for code, name := range ErrorNames {
+			description.Errors = append(description.Errors, ErrorDesc{Name: name, Code: code, Key: errorDetails[code].Key})
+		}
+		sort.Slice(description.Errors, func(i, j int) bool {
+			return description.Errors[i].Code < description.Errors[j].Code
+		})
+
+		description.CommonErrors = errorNames(CommonErrors)
+
+		description.Functions = make([]FunctionDesc, len(v.Functions))
+		for i, f := range v.Functions {
+			errors := errorNames(f.Errors)
+
+			description.Functions[i] = FunctionDesc{
+				Name:   f.Name,
+				Number: uint8(i),
+				Auth:   f.AuthHandler != nil,
+				Args:   describeValue(f.Args),
+				Resp:   describeValue(f.Resp),
+				Errors: errors,
+			}
+		}
+	})
+
+	return v.description
+}
+
+// HandleFDescribe: describe the version called
+func HandleFDescribe(ctx context.Context, _ database.Store, _ []byte) (interface{}, error) {
+	return VersionOf(ctx).Describe(), nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrorDetail: explanation of an error response
+type ErrorDetail struct {
+	Key     string // stable message key for translations, e.g. "args.type"
+	Message string // English text
+	Field   string `msgpack:",omitempty" json:",omitempty"` // path of the offending argument, e.g. "Requests.1.Func"
+}
+
+// errorDetails: default details of the error codes
+var errorDetails = map[uint8]ErrorDetail{
+	EExists:          {Key: "exists", Message: "record already exists"},
+	ENoEntry:         {Key: "no_entry", Message: "record not found"},
+	EPassWrong:       {Key: "pass_wrong", Message: "wrong login or password"},
+	ENotLoggedIn:     {Key: "not_logged_in", Message: "session token is invalid or expired"},
+	EAccessDenied:    {Key: "access_denied", Message: "access denied"},
+	ETooManyRequests: {Key: "too_many_requests", Message: "too many requests, try again later"},
+	ETooLarge:        {Key: "too_large", Message: "request is too large"},
+	EArgsInval:       {Key: "args.invalid", Message: "invalid arguments"},
+	ENoFun:           {Key: "no_function", Message: "function does not exist"},
+	EUnknown:         {Key: "unknown", Message: "internal error"},
+}
+
+var errorDetailType = reflect.TypeOf((*ErrorDetail)(nil))
+
+// WithErrorDetail: set the default Error detail of an error response
+// that has none; other values are returned unchanged
+func WithErrorDetail(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Struct {
+		return v
+	}
+
+	code := rv.FieldByName("Code")
+	detail := rv.FieldByName("Error")
+	if !code.IsValid() || code.Kind() != reflect.Uint8 || code.Uint() == 0 ||
+		!detail.IsValid() || detail.Type() != errorDetailType || !detail.IsNil() {
+		return v
+	}
+
+	d, ok := errorDetails[uint8(code.Uint())]
+	if !ok {
+		return v
+	}
+
+	// copy, the field of v is not settable
+	resp := reflect.New(rv.Type()).Elem()
+	resp.Set(rv)
+	resp.FieldByName("Error").Set(reflect.ValueOf(&d))
+	return resp.Interface()
+}
+
+// argsError: EArgsInval response for arguments data that failed to decode
+// into v, pointing at the offending field where it can be found
+func argsError(data []byte, v interface{}) Response {
+	path, key, msg := findArgsError(data, reflect.TypeOf(v).Elem())
+	if key == "" {
+		// the parts decode, but not the whole
+		key, msg = "args.invalid", "invalid arguments"
+	}
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	return Response{
+		Code:  EArgsInval,
+		Error: &ErrorDetail{Key: key, Message: msg, Field: path},
+	}
+}
+
+// findArgsError: decode data as t part by part to find the first field
+// that fails; returns its path, message key and message
+func findArgsError(data []byte, t reflect.Type) (path, key, msg string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == rawMessageType:
+		return "", "args.invalid", "invalid arguments"
+
+	case t.Kind() == reflect.Struct:
+		var fields map[string]msgpack.RawMessage
+		if err := msgpack.Unmarshal(data, &fields); err != nil {
+			return "", "args.malformed", "expected an object"
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names) // report the same field every time
+
+		for _, name := range names {
+			raw := fields[name]
+			f, ok := t.FieldByName(name)
+			if !ok || !f.IsExported() {
+				return name, "args.unknown_field", "unknown field"
+			}
+			if p, k, m := findArgsError(raw, f.Type); k != "" {
+				return joinPath(name, p), k, m
+			}
+		}
+		return "", "", ""
+
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		var elems []msgpack.RawMessage
+		if err := msgpack.Unmarshal(data, &elems); err != nil {
+			return "", "args.type", "expected an array"
+		}
+		for i, raw := range elems {
+			if p, k, m := findArgsError(raw, t.Elem()); k != "" {
+				return joinPath(strconv.Itoa(i), p), k, m
+			}
+		}
+		return "", "", ""
+
+	default:
+		if err := CustomUnmarshal(data, reflect.New(t).Interface()); err != nil {
+			name, _, _ := describeType(t, nil)
+			return "", "args.type", fmt.Sprintf("expected %s", name)
+		}
+		return "", "", ""
+	}
+}
+
+func joinPath(parent, child string) string {
+	if parent == "" || child == "" {
+		return parent + child
+	}
+	return parent + "." + child
+}
@@ -0,0 +1,229 @@
+package api
+
+import "BastetSoftware/backend/database"
+
+//go:generate go run ../cmd/apidoc -o SCHEMA.md
+//go:generate go run ../cmd/apidoc -version v2 -o SCHEMA.v2.md
+
+// Function: API function with the description of its arguments and results
+type Function struct {
+	Name        string
+	Handler     RequestHandler
+	AuthHandler AuthRequestHandler // set instead of Handler for functions that require a session
+	Args        interface{}        // arguments struct, nil if the function takes no arguments
+	Resp        interface{}        // response struct
+	Errors      []uint8            // error codes the function can return
+}
+
+// ErrorNames: names of the error codes
+var ErrorNames = map[uint8]string{
+	EExists:          "EExists",
+	ENoEntry:         "ENoEntry",
+	EPassWrong:       "EPassWrong",
+	ENotLoggedIn:     "ENotLoggedIn",
+	EAccessDenied:    "EAccessDenied",
+	ETooManyRequests: "ETooManyRequests",
+	ETooLarge:        "ETooLarge",
+	EArgsInval:       "EArgsInval",
+	ENoFun:           "ENoFun",
+	EUnknown:         "EUnknown",
+}
+
+// CommonErrors: error codes any function can return, in addition to its Errors
+var CommonErrors = []uint8{ETooManyRequests, ETooLarge}
+
+func init() {
+	// function numbers are the positions in this list, only append to it
+	functions := []Function{
+		{
+			Name:    "ping",
+			Handler: HandleFPing,
+			Resp:    Response{},
+		},
+
+		{
+			Name:    "user_create",
+			Handler: HandleFUserCreate,
+			Args:    ArgsFUserCreate{},
+			Resp:    Response{},
+			Errors:  []uint8{EArgsInval, EExists},
+		},
+		{
+			Name:    "user_log_in",
+			Handler: HandleFLogIn,
+			Args:    ArgsFLogIn{},
+			Resp:    RespFLogIn{},
+			Errors:  []uint8{EArgsInval, EPassWrong},
+		},
+		{
+			Name:        "user_log_out",
+			AuthHandler: HandleFLogOut,
+			Args:        ArgsFLogOut{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn},
+		},
+		{
+			Name:        "user_get_info",
+			AuthHandler: HandleFUserInfo,
+			Args:        ArgsFUserInfo{},
+			Resp:        RespFUserInfo{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+		{
+			Name:        "user_edit",
+			AuthHandler: HandleFUserEdit,
+			Args:        ArgsFUserEdit{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, EExists},
+		},
+		{
+			Name:        "user_set_manages_groups",
+			AuthHandler: HandleFUserSetManagesGroups,
+			Args:        ArgsFUserSetManagesGroups{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry, EAccessDenied},
+		},
+		{
+			Name:        "user_list_groups",
+			AuthHandler: HandleFUserListGroups,
+			Args:        ArgsFUserListGroups{},
+			Resp:        RespFUserListGroups{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+
+		{
+			Name:        "group_create",
+			AuthHandler: HandleFGroupCreate,
+			Args:        ArgsFGroupCreateRemove{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, EAccessDenied, EExists},
+		},
+		{
+			Name:        "group_remove",
+			AuthHandler: HandleFGroupRemove,
+			Args:        ArgsFGroupCreateRemove{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry, EAccessDenied},
+		},
+		{
+			Name:        "group_add_remove_user",
+			AuthHandler: HandleFGroupAddRemoveUser,
+			Args:        ArgsFGroupAddRemoveUser{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry, EAccessDenied, EExists},
+		},
+		{
+			Name:        "group_get_info",
+			AuthHandler: HandleFGroupGetInfo,
+			Args:        ArgsFGroupGetInfo{},
+			Resp:        RespFGroupGetInfo{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+
+		{
+			Name:        "object_create",
+			AuthHandler: HandleFStructCreate,
+			Args:        ArgsFStructCreate{},
+			Resp:        RespFStructCreate{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn},
+		},
+		{
+			Name:        "object_get_info",
+			AuthHandler: HandleFStructInfo,
+			Args:        ArgsFStructInfo{},
+			Resp:        RespFStructInfo{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+		{
+			Name:        "find_object",
+			AuthHandler: HandleFStructFind,
+			Args:        database.ArgsFStructFind{},
+			Resp:        RespFStructFind{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn},
+		},
+		{
+			Name:        "object_delete",
+			AuthHandler: HandleFDeleteStruct,
+			Args:        ArgsFDeleteStruct{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+		{
+			Name:        "object_change",
+			AuthHandler: HandleFStructEdit,
+			Args:        ArgsFStructEdit{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn},
+		},
+
+		{
+			Name:        "task_create",
+			AuthHandler: HandleFTaskCreate,
+			Args:        ArgsFTaskCreate{},
+			Resp:        RespFTaskCreate{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn},
+		},
+		{
+			Name:        "task_remove",
+			AuthHandler: HandleFTaskRemove,
+			Args:        ArgsFTaskRemove{},
+			Resp:        Response{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+		{
+			Name:        "task_get_info",
+			AuthHandler: HandleFTaskGetInfo,
+			Args:        ArgsFTaskGetInfo{},
+			Resp:        RespFTaskGetInfo{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+		{
+			Name:        "task_search",
+			AuthHandler: HandleFTaskSearch,
+			Args:        ArgsFTaskSearch{},
+			Resp:        RespFTaskSearch{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn},
+		},
+
+		{
+			Name:    "batch",
+			Handler: HandleFBatch,
+			Args:    ArgsFBatch{},
+			Resp:    RespFBatch{},
+			Errors:  []uint8{EArgsInval},
+		},
+		{
+			Name:    "describe",
+			Handler: HandleFDescribe,
+			Resp:    RespFDescribe{},
+		},
+	}
+
+	V1 = newVersion("v1", functions)
+
+	// v2: functions changed since v1, same numbers
+	V2 = newVersion("v2", override(functions, []Function{
+		{
+			Name:        "object_get_info",
+			AuthHandler: HandleFStructInfoV2,
+			Args:        ArgsFStructInfo{},
+			Resp:        RespFStructInfoV2{},
+			Errors:      []uint8{EArgsInval, ENotLoggedIn, ENoEntry},
+		},
+	}))
+
+	Versions = []*Version{V1, V2}
+}
+
+// Middleware: wrapper of the handler of an API function
+type Middleware func(f *Function, handler RequestHandler) RequestHandler
+
+// Wrap: wrap the handlers of all functions of all versions with mw, including
+// the ones called by batch; must be called before serving requests
+func Wrap(mw Middleware) {
+	for _, v := range Versions {
+		for i := range v.Functions {
+			v.Functions[i].Handler = mw(&v.Functions[i], v.Functions[i].Handler)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"testing"
+)
+
+// errorCase: arguments of a call failing with a documented error code,
+// prepare sets up the store before the call
+type errorCase struct {
+	prepare func(t *testing.T, db database.Store)
+	args    interface{}
+}
+
+func addTestObject(t *testing.T, db database.Store) {
+	if err := db.AddStruct(context.Background(), &database.StructInfo{Name: "Boiler house", Gid: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// errorCases: calls producing the errors of each function, EArgsInval and
+// ENotLoggedIn are produced for every function that documents them
+var errorCases = map[string]map[uint8]errorCase{
+	"user_create": {
+		EExists: {args: ArgsFUserCreate{Login: "ivanov", Password: "password", FirstName: "I", LastName: "I"}},
+	},
+	"user_log_in": {
+		EPassWrong: {args: ArgsFLogIn{Login: "ivanov", Password: "wrong-password"}},
+	},
+	"user_get_info": {
+		ENoEntry: {args: ArgsFUserInfo{Token: testToken, Login: "sidorov"}},
+	},
+	"user_edit": {
+		EExists: {args: ArgsFUserEdit{Token: testToken, Login: strPtr("petrova")}},
+	},
+	"user_set_manages_groups": {
+		ENoEntry:      {args: ArgsFUserSetManagesGroups{Token: testToken, Login: "sidorov"}},
+		EAccessDenied: {args: ArgsFUserSetManagesGroups{Token: testToken2, Login: "petrova", Value: true}},
+	},
+	"user_list_groups": {
+		ENoEntry: {args: ArgsFUserListGroups{Token: testToken, Login: "sidorov"}},
+	},
+	"group_create": {
+		EAccessDenied: {args: ArgsFGroupCreateRemove{Token: testToken2, Name: "painters"}},
+		EExists:       {args: ArgsFGroupCreateRemove{Token: testToken, Name: "engineers"}},
+	},
+	"group_remove": {
+		ENoEntry:      {args: ArgsFGroupCreateRemove{Token: testToken, Name: "painters"}},
+		EAccessDenied: {args: ArgsFGroupCreateRemove{Token: testToken2, Name: "engineers"}},
+	},
+	"group_add_remove_user": {
+		ENoEntry:      {args: ArgsFGroupAddRemoveUser{Token: testToken, Group: "engineers", Login: "petrova", Action: false}},
+		EAccessDenied: {args: ArgsFGroupAddRemoveUser{Token: testToken2, Group: "engineers", Login: "petrova", Action: true}},
+		EExists: {
+			prepare: func(t *testing.T, db database.Store) {
+				if err := db.GroupAddUser(context.Background(), 2, 1); err != nil {
+					t.Fatal(err)
+				}
+			},
+			args: ArgsFGroupAddRemoveUser{Token: testToken, Group: "engineers", Login: "petrova", Action: true},
+		},
+	},
+	"group_get_info": {
+		ENoEntry: {args: ArgsFGroupGetInfo{Token: testToken, Gid: 5}},
+	},
+	"object_get_info": {
+		ENoEntry: {args: ArgsFStructInfo{Token: testToken, Id: 5}},
+	},
+	"object_delete": {
+		ENoEntry: {prepare: addTestObject, args: ArgsFDeleteStruct{Token: testToken, Id: 5}},
+	},
+	"task_remove": {
+		ENoEntry: {args: ArgsFTaskRemove{Token: testToken, Id: 5}},
+	},
+	"task_get_info": {
+		ENoEntry: {args: ArgsFTaskGetInfo{Token: testToken, Id: 5}},
+	},
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// TestErrorsProduced: every documented error of every function can be
+// produced, and no case produces an undocumented one
+func TestErrorsProduced(t *testing.T) {
+	for _, v := range Versions {
+		for _, f := range v.Functions {
+			documented := make(map[uint8]bool)
+			for _, code := range f.Errors {
+				documented[code] = true
+
+				var c errorCase
+				switch {
+				case errorCases[f.Name][code].args != nil:
+					c = errorCases[f.Name][code]
+				case code == EArgsInval && f.Args != nil:
+					c.args = map[string]interface{}{"Token": testToken, "Bogus": 1}
+				case code == ENotLoggedIn && f.AuthHandler != nil:
+					c.args = map[string]interface{}{"Token": "wrong"}
+				default:
+					t.Errorf("%s %s: no case for %s", v.Name, f.Name, ErrorNames[code])
+					continue
+				}
+
+				db := newTestStore(t)
+				if c.prepare != nil {
+					c.prepare(t, db)
+				}
+				var resp Response
+				call(t, db, v, f.Name, c.args, &resp)
+				if resp.Code != code {
+					t.Errorf("%s %s: got %s, want %s", v.Name, f.Name, ErrorNames[resp.Code], ErrorNames[code])
+				}
+			}
+
+			for code := range errorCases[f.Name] {
+				if !documented[code] {
+					t.Errorf("%s %s: %s is not documented", v.Name, f.Name, ErrorNames[code])
+				}
+			}
+		}
+	}
+}
@@ -1,51 +1,34 @@
 package api
 
-import "BastetSoftware/backend/database"
+import (
+	"BastetSoftware/backend/database"
+	"context"
+)
 
 // verifyManagesGroups: check that user can manage groups
-func verifyManagesGroups(token string) (interface{}, error) {
-	session, err := database.VerifySession(Db, []byte(token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
-	}
-
-	userinfo, err := database.GetUserInfo(Db, session.User)
-	switch err {
-	case nil:
-		break
-	case database.ErrNoUser:
-		return Response{Code: ENoEntry}, nil
-	default:
-		return Response{Code: EUnknown}, err
-	}
-
-	if !userinfo.ManagesGroups {
+func verifyManagesGroups(caller *Caller) (interface{}, error) {
+	if !caller.User.ManagesGroups {
 		return Response{Code: EAccessDenied}, nil
 	}
 
 	return nil, nil
 }
 
-func HandleFGroupCreate(r []byte) (interface{}, error) {
+func HandleFGroupCreate(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFGroupCreateRemove
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	// check that user can manage groups
-	resp, err := verifyManagesGroups(args.Token)
+	resp, err := verifyManagesGroups(caller)
 	if resp != nil {
 		return resp, err
 	}
 
-	_, err = database.CreateGroup(Db, args.Name)
+	_, err = db.CreateGroup(ctx, args.Name)
 	switch err {
 	case nil:
 		break
@@ -58,21 +41,21 @@ func HandleFGroupCreate(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFGroupRemove(r []byte) (interface{}, error) {
+func HandleFGroupRemove(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFGroupCreateRemove
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	// check that user can manage groups
-	resp, err := verifyManagesGroups(args.Token)
+	resp, err := verifyManagesGroups(caller)
 	if resp != nil {
 		return resp, err
 	}
 
-	group, err := database.FindGroup(Db, args.Name)
+	group, err := db.FindGroup(ctx, args.Name)
 	switch err {
 	case nil:
 		break
@@ -82,7 +65,7 @@ func HandleFGroupRemove(r []byte) (interface{}, error) {
 		return Response{Code: EUnknown}, err
 	}
 
-	err = database.RemoveGroup(Db, group.Id)
+	err = db.RemoveGroup(ctx, group.Id)
 	switch err {
 	case nil:
 		break
@@ -95,21 +78,21 @@ func HandleFGroupRemove(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFGroupAddRemoveUser(r []byte) (interface{}, error) {
+func HandleFGroupAddRemoveUser(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFGroupAddRemoveUser
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	// check that user can manage groups
-	resp, err := verifyManagesGroups(args.Token)
+	resp, err := verifyManagesGroups(caller)
 	if resp != nil {
 		return resp, err
 	}
 
-	group, err := database.FindGroup(Db, args.Group)
+	group, err := db.FindGroup(ctx, args.Group)
 	switch err {
 	case nil:
 		break
@@ -119,7 +102,7 @@ func HandleFGroupAddRemoveUser(r []byte) (interface{}, error) {
 		return Response{Code: EUnknown}, err
 	}
 
-	user, err := database.FindUserInfo(Db, args.Login)
+	user, err := db.FindUserInfo(ctx, args.Login)
 	switch err {
 	case nil:
 		break
@@ -130,9 +113,9 @@ func HandleFGroupAddRemoveUser(r []byte) (interface{}, error) {
 	}
 
 	if args.Action {
-		err = database.GroupAddUser(Db, user.Id, group.Id)
+		err = db.GroupAddUser(ctx, user.Id, group.Id)
 	} else {
-		err = database.GroupRemoveUser(Db, user.Id, group.Id)
+		err = db.GroupRemoveUser(ctx, user.Id, group.Id)
 	}
 
 	switch err {
@@ -140,7 +123,7 @@ func HandleFGroupAddRemoveUser(r []byte) (interface{}, error) {
 		break
 	case database.ErrAlreadyInGroup:
 		return Response{Code: EExists}, nil
-	case database.ErrNoGroup:
+	case database.ErrNoGroup, database.ErrNotInGroup:
 		return Response{Code: ENoEntry}, nil
 	default:
 		return Response{Code: EUnknown}, err
@@ -149,16 +132,16 @@ func HandleFGroupAddRemoveUser(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFGroupGetInfo(r []byte) (interface{}, error) {
+func HandleFGroupGetInfo(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFGroupGetInfo
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	// get group info
-	group, err := database.GetGroup(Db, args.Gid)
+	group, err := db.GetGroup(ctx, args.Gid)
 	switch err {
 	case nil:
 		break
@@ -169,7 +152,7 @@ func HandleFGroupGetInfo(r []byte) (interface{}, error) {
 	}
 
 	// get group's users
-	uids, err := database.ListGroupsOrUsers(Db, database.GroupListUsers, args.Gid)
+	uids, err := db.ListGroupsOrUsers(ctx, database.GroupListUsers, args.Gid)
 	if err != nil {
 		return Response{Code: EUnknown}, err
 	}
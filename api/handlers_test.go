@@ -0,0 +1,189 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	db := newTestStore(t)
+	ctx := context.Background()
+
+	var login RespFLogIn
+	for _, args := range []ArgsFLogIn{
+		{Login: "ivanov", Password: "wrong-password"},
+		{Login: "sidorov", Password: testPassword},
+	} {
+		call(t, db, V2, "user_log_in", args, &login)
+		if login.Code != EPassWrong || login.Token != "" {
+			t.Errorf("user_log_in %s/%s: got %+v", args.Login, args.Password, login)
+		}
+	}
+
+	call(t, db, V2, "user_log_in", ArgsFLogIn{Login: "petrova", Password: testPassword}, &login)
+	if login.Code != 0 || login.Token == "" {
+		t.Fatalf("user_log_in: got %+v", login)
+	}
+
+	var info RespFUserInfo
+	call(t, db, V2, "user_get_info", ArgsFUserInfo{Token: login.Token, Login: "ivanov"}, &info)
+	if info.Code != 0 || info.FirstName != "Ivan" {
+		t.Errorf("user_get_info with the new token: got %+v", info)
+	}
+
+	var resp Response
+	call(t, db, V2, "user_log_out", ArgsFLogOut{Token: login.Token}, &resp)
+	if resp.Code != 0 {
+		t.Errorf("user_log_out: got %+v", resp)
+	}
+
+	expired := database.Session{Token: []byte("expired"), ExpiryDate: time.Now().Add(-time.Minute).Unix(), User: 1}
+	if err := db.AddSession(ctx, &expired); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"", "made-up", "expired", login.Token} {
+		call(t, db, V2, "user_get_info", ArgsFUserInfo{Token: token, Login: "ivanov"}, &resp)
+		if resp.Code != ENotLoggedIn {
+			t.Errorf("user_get_info with token %q: got %+v, want ENotLoggedIn", token, resp)
+		}
+	}
+
+	// the session decides the caller, not the arguments
+	call(t, db, V2, "group_create", ArgsFGroupCreateRemove{Token: testToken2, Name: "accounting"}, &resp)
+	if resp.Code != EAccessDenied {
+		t.Errorf("group_create by petrova: got %+v, want EAccessDenied", resp)
+	}
+	call(t, db, V2, "group_create", ArgsFGroupCreateRemove{Token: testToken, Name: "accounting"}, &resp)
+	if resp.Code != 0 {
+		t.Errorf("group_create by ivanov: got %+v", resp)
+	}
+}
+
+func TestBatchAtomic(t *testing.T) {
+	db := newTestStore(t)
+	ctx := context.Background()
+	fn := func(name string) uint8 { return funcNumber(t, V2, name) }
+	changed := "Changed"
+
+	requests := []Request{
+		{Func: fn("group_create"), Args: marshal(t, ArgsFGroupCreateRemove{Token: testToken, Name: "surveyors"})},
+		{Func: fn("user_edit"), Args: marshal(t, ArgsFUserEdit{Token: testToken, FirstName: &changed})},
+		// fails, the group exists
+		{Func: fn("group_create"), Args: marshal(t, ArgsFGroupCreateRemove{Token: testToken, Name: "engineers"})},
+		{Func: fn("group_create"), Args: marshal(t, ArgsFGroupCreateRemove{Token: testToken, Name: "not run"})},
+	}
+
+	var resp struct {
+		Code      uint8
+		Responses []Response
+	}
+	call(t, db, V2, "batch", ArgsFBatch{Atomic: true, Requests: requests}, &resp)
+	if resp.Code != EExists || len(resp.Responses) != 3 {
+		t.Fatalf("atomic batch: got %+v, want EExists after 3 responses", resp)
+	}
+	if _, err := db.FindGroup(ctx, "surveyors"); err != database.ErrNoGroup {
+		t.Errorf("group of a failed atomic batch: got %v, want ErrNoGroup", err)
+	}
+	if u, err := db.FindUserInfo(ctx, "ivanov"); err != nil || u.FirstName != "Ivan" {
+		t.Errorf("user of a failed atomic batch: got %+v, %v", u, err)
+	}
+
+	// without Atomic, the calls before and after the failed one stay done
+	call(t, db, V2, "batch", ArgsFBatch{Requests: requests}, &resp)
+	if resp.Code != 0 || len(resp.Responses) != 4 || resp.Responses[2].Code != EExists {
+		t.Fatalf("batch: got %+v", resp)
+	}
+	for _, name := range []string{"surveyors", "not run"} {
+		if _, err := db.FindGroup(ctx, name); err != nil {
+			t.Errorf("group %s of a batch: %v", name, err)
+		}
+	}
+	if u, err := db.FindUserInfo(ctx, "ivanov"); err != nil || u.FirstName != changed {
+		t.Errorf("user of a batch: got %+v, %v", u, err)
+	}
+}
+
+func TestUserEditRollback(t *testing.T) {
+	db := newTestStore(t)
+	ctx := context.Background()
+	taken, name, password := "petrova", "Changed", "new-password"
+
+	var resp Response
+	call(t, db, V2, "user_edit", ArgsFUserEdit{Token: testToken, Login: &taken, FirstName: &name, Password: &password}, &resp)
+	if resp.Code != EExists {
+		t.Fatalf("user_edit to a taken login: got %+v, want EExists", resp)
+	}
+
+	u, err := db.GetUserInfo(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Login != "ivanov" || u.FirstName != "Ivan" {
+		t.Errorf("user after a failed user_edit: got %+v", u)
+	}
+	var login RespFLogIn
+	call(t, db, V2, "user_log_in", ArgsFLogIn{Login: "ivanov", Password: testPassword}, &login)
+	if login.Code != 0 {
+		t.Errorf("user_log_in with the old password: got %+v", login)
+	}
+
+	free := "sidorov"
+	call(t, db, V2, "user_edit", ArgsFUserEdit{Token: testToken, Login: &free, FirstName: &name}, &resp)
+	if resp.Code != 0 {
+		t.Fatalf("user_edit: got %+v", resp)
+	}
+	if u, err = db.GetUserInfo(ctx, 1); err != nil || u.Login != free || u.FirstName != name {
+		t.Errorf("user after user_edit: got %+v, %v", u, err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	db := newTestStore(t)
+	addTestObject(t, db)
+	object := int64(1)
+
+	// function numbers do not change between versions
+	if len(V2.Functions) < len(V1.Functions) {
+		t.Fatalf("v2 has %d functions, v1 %d", len(V2.Functions), len(V1.Functions))
+	}
+	for i, f := range V1.Functions {
+		if V2.Functions[i].Name != f.Name {
+			t.Errorf("function %d: %s in v1, %s in v2", i, f.Name, V2.Functions[i].Name)
+		}
+	}
+
+	var v1, v2 map[string]interface{}
+	args := ArgsFStructInfo{Token: testToken, Id: object}
+	call(t, db, V1, "object_get_info", args, &v1)
+	call(t, db, V2, "object_get_info", args, &v2)
+	if _, ok := v1["Code"]; ok || v1["Name"] != "Boiler house" {
+		t.Errorf("object_get_info of v1: got %v, want no Code", v1)
+	}
+	if code, ok := v2["Code"]; !ok || fmt.Sprint(code) != "0" || v2["Name"] != "Boiler house" {
+		t.Errorf("object_get_info of v2: got %v, want Code 0", v2)
+	}
+
+	// errors are the same
+	args.Id = object + 100
+	for _, v := range []*Version{V1, V2} {
+		var resp Response
+		call(t, db, v, "object_get_info", args, &resp)
+		if resp.Code != ENoEntry {
+			t.Errorf("object_get_info of a missing object in %s: got %+v", v.Name, resp)
+		}
+	}
+
+	// describe reports the functions of the version it is called in
+	for _, v := range []*Version{V1, V2} {
+		var desc RespFDescribe
+		call(t, db, v, "describe", nil, &desc)
+		f := desc.Functions[funcNumber(t, v, "object_get_info")]
+		hasCode := len(f.Resp) > 0 && f.Resp[0].Name == "Code"
+		if hasCode != (v == V2) {
+			t.Errorf("describe of %s: object_get_info response %v", v.Name, f.Resp)
+		}
+	}
+}
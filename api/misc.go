@@ -1,9 +1,14 @@
 package api
 
-func UnknownFPlug(_ []byte) (interface{}, error) {
+import (
+	"BastetSoftware/backend/database"
+	"context"
+)
+
+func UnknownFPlug(_ context.Context, _ database.Store, _ []byte) (interface{}, error) {
 	return Response{Code: ENoFun}, nil
 }
 
-func HandleFPing(_ []byte) (interface{}, error) {
+func HandleFPing(_ context.Context, _ database.Store, _ []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
@@ -2,26 +2,15 @@ package api
 
 import (
 	"BastetSoftware/backend/database"
-
-	"github.com/vmihailenco/msgpack/v5"
+	"context"
 )
 
-func HandleFStructCreate(r []byte) (interface{}, error) {
+func HandleFStructCreate(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFStructCreate
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
-	}
-
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
+		return argsError(r, &args), err
 	}
 
 	structInfo := database.StructInfo{
@@ -39,13 +28,8 @@ func HandleFStructCreate(r []byte) (interface{}, error) {
 		Gid:         args.Gid,
 		Permissions: args.Permissions,
 	}
-	err = structInfo.AddStruct(Db)
-	switch err {
-	case nil:
-		break
-	case database.ErrStructExists:
-		return Response{Code: EExists}, nil
-	default:
+	err = db.AddStruct(ctx, &structInfo)
+	if err != nil {
 		return Response{Code: EUnknown}, err
 	}
 
@@ -53,32 +37,32 @@ func HandleFStructCreate(r []byte) (interface{}, error) {
 	return RespFStructCreate{Code: 0, Id: structInfo.Id}, nil
 }
 
-func HandleFStructInfo(r []byte) (interface{}, error) {
+// getStructInfo: object of the arguments, or the error response
+func getStructInfo(ctx context.Context, db database.Store, r []byte) (*database.StructInfo, interface{}, error) {
 	// parse args
 	var args ArgsFStructInfo
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return nil, argsError(r, &args), err
 	}
 
-	_, err = database.VerifySession(Db, []byte(args.Token))
+	structInfo, err := db.GetStructInfo(ctx, args.Id)
 	switch err {
 	case nil:
 		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
+	case database.ErrNoStruct:
+		return nil, Response{Code: ENoEntry}, nil
 	default:
-		return Response{Code: EUnknown}, err
+		return nil, Response{Code: EUnknown}, err
 	}
 
-	structInfo, err := database.GetStructInfo(Db, args.Id)
-	switch err {
-	case nil:
-		break
-	case database.ErrNoStruct:
-		return Response{Code: ENoEntry}, nil
-	default:
-		return Response{Code: EUnknown}, err
+	return structInfo, nil, nil
+}
+
+func HandleFStructInfo(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
+	structInfo, resp, err := getStructInfo(ctx, db, r)
+	if resp != nil {
+		return resp, err
 	}
 
 	return RespFStructInfo{
@@ -97,30 +81,39 @@ func HandleFStructInfo(r []byte) (interface{}, error) {
 	}, nil
 }
 
-func HandleFStructFind(r []byte) (interface{}, error) {
+// HandleFStructInfoV2: object_get_info of v2, the response has Code
+func HandleFStructInfoV2(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
+	structInfo, resp, err := getStructInfo(ctx, db, r)
+	if resp != nil {
+		return resp, err
+	}
+
+	return RespFStructInfoV2{
+		Code:        0,
+		Name:        structInfo.Name,
+		Description: structInfo.Description,
+		District:    structInfo.District,
+		Region:      structInfo.Region,
+		Address:     structInfo.Address,
+		Type:        structInfo.Type,
+		State:       structInfo.State,
+		Area:        structInfo.Area,
+		Owner:       structInfo.Owner,
+		Actual_user: structInfo.Actual_user,
+		Gid:         structInfo.Gid,
+		Permissions: structInfo.Permissions,
+	}, nil
+}
+
+func HandleFStructFind(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	var args database.ArgsFStructFind
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
-	}
-
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
+		return argsError(r, &args), err
 	}
 
-	structsInfo, err := database.FindStructures(Db, args)
-	switch err {
-	case nil:
-		break
-	case database.ErrNoStruct:
-		return Response{Code: ENoEntry}, nil
-	default:
+	structsInfo, err := db.FindStructures(ctx, args)
+	if err != nil {
 		return Response{Code: EUnknown}, err
 	}
 
@@ -130,23 +123,14 @@ func HandleFStructFind(r []byte) (interface{}, error) {
 	}, nil
 }
 
-func HandleFDeleteStruct(r []byte) (interface{}, error) {
+func HandleFDeleteStruct(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	var args ArgsFDeleteStruct
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
-	}
-	err = database.DeleteStruct(Db, args.Id)
+	err = db.DeleteStruct(ctx, args.Id)
 	switch err {
 	case nil:
 		break
@@ -158,27 +142,26 @@ func HandleFDeleteStruct(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFStructEdit(r []byte) (interface{}, error) {
+func HandleFStructEdit(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	var args ArgsFStructEdit
-	err := msgpack.Unmarshal(r, &args)
-	if err != nil || args.Token == "" {
-		return Response{Code: EArgsInval}, err
+	err := CustomUnmarshal(r, &args)
+	if err != nil {
+		return argsError(r, &args), err
 	}
 
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
-	}
+	// all fields change or none
+	return atomically(ctx, db, func(tx database.Store) (interface{}, error) {
+		return structEdit(ctx, tx, &args)
+	})
+}
 
+// structEdit: change the fields set in args
+func structEdit(ctx context.Context, db database.Store, args *ArgsFStructEdit) (interface{}, error) {
+	var err error
 	uid := args.Id
 
 	if args.Name != nil {
-		err = database.StructChangeName(Db, uid, *args.Name)
+		err = db.StructChangeName(ctx, uid, *args.Name)
 		switch err {
 		case nil:
 			break
@@ -188,7 +171,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Description != nil {
-		err = database.StructChangeDescription(Db, uid, *args.Description)
+		err = db.StructChangeDescription(ctx, uid, *args.Description)
 		switch err {
 		case nil:
 			break
@@ -198,7 +181,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.District != nil {
-		err = database.StructChangeDistrict(Db, uid, *args.District)
+		err = db.StructChangeDistrict(ctx, uid, *args.District)
 		switch err {
 		case nil:
 			break
@@ -208,7 +191,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Region != nil {
-		err = database.StructChangeRegion(Db, uid, *args.Region)
+		err = db.StructChangeRegion(ctx, uid, *args.Region)
 		switch err {
 		case nil:
 			break
@@ -218,7 +201,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Address != nil {
-		err = database.StructChangeAddress(Db, uid, *args.Address)
+		err = db.StructChangeAddress(ctx, uid, *args.Address)
 		switch err {
 		case nil:
 			break
@@ -228,7 +211,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Type != nil {
-		err = database.StructChangeType(Db, uid, *args.Type)
+		err = db.StructChangeType(ctx, uid, *args.Type)
 		switch err {
 		case nil:
 			break
@@ -238,7 +221,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.State != nil {
-		err = database.StructChangeState(Db, uid, *args.State)
+		err = db.StructChangeState(ctx, uid, *args.State)
 		switch err {
 		case nil:
 			break
@@ -248,7 +231,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Area != nil {
-		err = database.StructChangeArea(Db, uid, *args.Area)
+		err = db.StructChangeArea(ctx, uid, *args.Area)
 		switch err {
 		case nil:
 			break
@@ -258,7 +241,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Owner != nil {
-		err = database.StructChangeOwner(Db, uid, *args.Owner)
+		err = db.StructChangeOwner(ctx, uid, *args.Owner)
 		switch err {
 		case nil:
 			break
@@ -268,7 +251,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Actual_user != nil {
-		err = database.StructChangeActualUser(Db, uid, *args.Actual_user)
+		err = db.StructChangeActualUser(ctx, uid, *args.Actual_user)
 		switch err {
 		case nil:
 			break
@@ -278,7 +261,7 @@ func HandleFStructEdit(r []byte) (interface{}, error) {
 	}
 
 	if args.Permissions != nil {
-		err = database.StructChangePermissions(Db, uid, *args.Permissions)
+		err = db.StructChangePermissions(ctx, uid, *args.Permissions)
 		switch err {
 		case nil:
 			break
@@ -2,25 +2,16 @@ package api
 
 import (
 	"BastetSoftware/backend/database"
+	"context"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
-func HandleFTaskCreate(r []byte) (interface{}, error) {
+func HandleFTaskCreate(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFTaskCreate
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
-	}
-
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
+		return argsError(r, &args), err
 	}
 
 	task := database.Task{
@@ -34,38 +25,23 @@ func HandleFTaskCreate(r []byte) (interface{}, error) {
 		Gid:         args.Gid,
 		Permissions: args.Permissions,
 	}
-	task.Id, err = database.CreateTask(Db, &task)
-	switch err {
-	case nil:
-		break
-	case database.ErrTaskExists:
-		return Response{Code: EExists}, nil
-	default:
+	task.Id, err = db.CreateTask(ctx, &task)
+	if err != nil {
 		return Response{Code: EUnknown}, err
 	}
 
 	return RespFTaskCreate{Code: 0, Id: task.Id}, nil
 }
 
-func HandleFTaskRemove(r []byte) (interface{}, error) {
+func HandleFTaskRemove(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFTaskRemove
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
-	}
-
-	err = database.RemoveTask(Db, args.Id)
+	err = db.RemoveTask(ctx, args.Id)
 	switch err {
 	case nil:
 		break
@@ -78,25 +54,15 @@ func HandleFTaskRemove(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFTaskGetInfo(r []byte) (interface{}, error) {
+func HandleFTaskGetInfo(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFTaskGetInfo
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
-	}
-
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
+		return argsError(r, &args), err
 	}
 
-	task, err := database.GetTask(Db, args.Id)
+	task, err := db.GetTask(ctx, args.Id)
 	switch err {
 	case nil:
 		break
@@ -119,22 +85,12 @@ func HandleFTaskGetInfo(r []byte) (interface{}, error) {
 	}, nil
 }
 
-func HandleFTaskSearch(r []byte) (interface{}, error) {
+func HandleFTaskSearch(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFTaskSearch
 	err := msgpack.Unmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
-	}
-
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
+		return argsError(r, &args), err
 	}
 
 	filter := database.TaskFilter{
@@ -150,7 +106,7 @@ func HandleFTaskSearch(r []byte) (interface{}, error) {
 		Limit:  args.Limit,
 		Offset: args.Offset,
 	}
-	tasks, err := database.FilterTasks(Db, &filter)
+	tasks, err := db.FilterTasks(ctx, &filter)
 	if err != nil {
 		return Response{Code: EUnknown}, err
 	}
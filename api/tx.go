@@ -0,0 +1,35 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"errors"
+)
+
+// errRollback: rolls back the transaction of a call that failed without an error
+var errRollback = errors.New("call failed")
+
+// atomically: run fn in a transaction, rolled back if fn returns an error
+// or a response with a non-zero code
+func atomically(ctx context.Context, db database.Store, fn func(tx database.Store) (interface{}, error)) (interface{}, error) {
+	var response interface{}
+	err := db.WithTx(ctx, func(tx database.Store) error {
+		var err error
+		response, err = fn(tx)
+		if err == nil && ResponseCode(response) != 0 {
+			return errRollback
+		}
+		return err
+	})
+
+	switch {
+	case err == nil || err == errRollback:
+		return response, nil
+	case response != nil && ResponseCode(response) != 0:
+		// the error of fn
+		return response, err
+	default:
+		// the transaction could not start or commit
+		return Response{Code: EUnknown}, err
+	}
+}
@@ -2,17 +2,17 @@ package api
 
 import (
 	"BastetSoftware/backend/database"
+	"context"
 
-	"github.com/vmihailenco/msgpack/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HandleFUserCreate(r []byte) (interface{}, error) {
+func HandleFUserCreate(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFUserCreate
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	passHash, err := bcrypt.GenerateFromPassword([]byte(args.Password), bcrypt.DefaultCost)
@@ -29,7 +29,7 @@ func HandleFUserCreate(r []byte) (interface{}, error) {
 		Patronymic:    args.Patronymic,
 		ManagesGroups: false,
 	}
-	err = userInfo.Register(Db)
+	userInfo.Id, err = db.RegisterUser(ctx, &userInfo)
 	switch err {
 	case nil:
 		break
@@ -43,20 +43,18 @@ func HandleFUserCreate(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFLogIn(r []byte) (interface{}, error) {
+func HandleFLogIn(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFLogIn
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
-	session, err := database.OpenSession(Db, args.Login, args.Password)
+	session, err := database.OpenSession(ctx, db, args.Login, args.Password)
 	switch err {
 	case nil:
 		break
-	case database.ErrNoUser:
-		return Response{Code: ENoEntry}, nil
 	case database.ErrPassWrong:
 		return Response{Code: EPassWrong}, nil
 	default:
@@ -66,41 +64,36 @@ func HandleFLogIn(r []byte) (interface{}, error) {
 	return RespFLogIn{Code: 0, Token: string(session.Token)}, nil
 }
 
-func HandleFLogOut(r []byte) (interface{}, error) {
+func HandleFLogOut(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFLogOut
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
-	err = database.CloseSession(Db, []byte(args.Token))
-	if err != nil {
+	err = db.CloseSession(ctx, caller.Session.Token)
+	switch err {
+	case nil:
+		break
+	case database.ErrNotLoggedIn:
+		return Response{Code: ENotLoggedIn}, nil
+	default:
 		return Response{Code: EUnknown}, err
 	}
 
 	return Response{Code: 0}, nil
 }
 
-func HandleFUserInfo(r []byte) (interface{}, error) {
+func HandleFUserInfo(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFUserInfo
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
-	_, err = database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
-	}
-
-	userinfo, err := database.FindUserInfo(Db, args.Login)
+	userinfo, err := db.FindUserInfo(ctx, args.Login)
 	switch err {
 	case nil:
 		break
@@ -119,28 +112,35 @@ func HandleFUserInfo(r []byte) (interface{}, error) {
 	}, nil
 }
 
-func HandleFUserEdit(r []byte) (interface{}, error) {
+func HandleFUserEdit(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFUserEdit
-	err := msgpack.Unmarshal(r, &args)
-	if err != nil || args.Token == "" {
-		return Response{Code: EArgsInval}, err
+	err := CustomUnmarshal(r, &args)
+	if err != nil {
+		return argsError(r, &args), err
 	}
 
-	session, err := database.VerifySession(Db, []byte(args.Token))
-	switch err {
-	case nil:
-		break
-	case database.ErrNotLoggedIn:
-		return Response{Code: ENotLoggedIn}, nil
-	default:
-		return Response{Code: EUnknown}, err
+	// hashed before the transaction, it takes a while
+	var passHash []byte
+	if args.Password != nil {
+		passHash, err = bcrypt.GenerateFromPassword([]byte(*args.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return Response{Code: EUnknown}, err
+		}
 	}
 
-	uid := session.User
+	// all fields change or none
+	return atomically(ctx, db, func(tx database.Store) (interface{}, error) {
+		return userEdit(ctx, tx, caller.User.Id, &args, passHash)
+	})
+}
+
+// userEdit: change the fields set in args of the user uid
+func userEdit(ctx context.Context, db database.Store, uid int64, args *ArgsFUserEdit, passHash []byte) (interface{}, error) {
+	var err error
 
 	if args.Login != nil {
-		err = database.UserChangeLogin(Db, uid, *args.Login)
+		err = db.UserChangeLogin(ctx, uid, *args.Login)
 		switch err {
 		case nil:
 			break
@@ -153,12 +153,8 @@ func HandleFUserEdit(r []byte) (interface{}, error) {
 		}
 	}
 
-	if args.Password != nil {
-		passHash, err := bcrypt.GenerateFromPassword([]byte(*args.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return Response{Code: EUnknown}, err
-		}
-		err = database.UserChangePasswordHash(Db, uid, passHash)
+	if passHash != nil {
+		err = db.UserChangePasswordHash(ctx, uid, passHash)
 		switch err {
 		case nil:
 			break
@@ -175,7 +171,7 @@ func HandleFUserEdit(r []byte) (interface{}, error) {
 			continue
 		}
 
-		err = database.UserChangeName(Db, uid, i, *n)
+		err = db.UserChangeName(ctx, uid, i, *n)
 		switch err {
 		case nil:
 			break
@@ -189,22 +185,22 @@ func HandleFUserEdit(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFUserSetManagesGroups(r []byte) (interface{}, error) {
+func HandleFUserSetManagesGroups(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFUserSetManagesGroups
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	// check that user can manage groups
-	resp, err := verifyManagesGroups(args.Token)
+	resp, err := verifyManagesGroups(caller)
 	if resp != nil {
 		return resp, err
 	}
 
 	// find target user
-	userinfo, err := database.FindUserInfo(Db, args.Login)
+	userinfo, err := db.FindUserInfo(ctx, args.Login)
 	switch err {
 	case nil:
 		break
@@ -214,7 +210,7 @@ func HandleFUserSetManagesGroups(r []byte) (interface{}, error) {
 		return Response{Code: EUnknown}, err
 	}
 
-	err = database.UserSetManagesGroups(Db, userinfo.Id, args.Value)
+	err = db.UserSetManagesGroups(ctx, userinfo.Id, args.Value)
 	switch err {
 	case nil:
 		break
@@ -227,16 +223,16 @@ func HandleFUserSetManagesGroups(r []byte) (interface{}, error) {
 	return Response{Code: 0}, nil
 }
 
-func HandleFUserListGroups(r []byte) (interface{}, error) {
+func HandleFUserListGroups(ctx context.Context, db database.Store, caller *Caller, r []byte) (interface{}, error) {
 	// parse args
 	var args ArgsFUserListGroups
 	err := CustomUnmarshal(r, &args)
 	if err != nil {
-		return Response{Code: EArgsInval}, err
+		return argsError(r, &args), err
 	}
 
 	// find target user
-	userinfo, err := database.FindUserInfo(Db, args.Login)
+	userinfo, err := db.FindUserInfo(ctx, args.Login)
 	switch err {
 	case nil:
 		break
@@ -247,7 +243,7 @@ func HandleFUserListGroups(r []byte) (interface{}, error) {
 	}
 
 	// get user groups
-	gids, err := database.ListGroupsOrUsers(Db, database.UserListGroups, userinfo.Id)
+	gids, err := db.ListGroupsOrUsers(ctx, database.UserListGroups, userinfo.Id)
 	if err != nil {
 		return Response{Code: EUnknown}, err
 	}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Argument rules are set with the validate tag of Args* fields, separated by commas:
+//
+//	required  string is not empty
+//	min=N     number is at least N, string or array has at least N elements
+//	max=N     number is at most N, string or array has at most N elements
+//
+// Pointer fields are checked only if present. Fields of nested structs and
+// of arrays of structs are checked too.
+
+// validationError: failed rule of an argument
+type validationError struct {
+	field string
+	rule  string
+	msg   string
+}
+
+// rule: rule of a validate tag
+type rule struct {
+	name string
+	n    int64 // parameter of min and max
+}
+
+// parseRules: rules of a validate tag
+func parseRules(tag string) ([]rule, error) {
+	var rules []rule
+	for _, s := range strings.Split(tag, ",") {
+		name, param, hasParam := strings.Cut(s, "=")
+
+		r := rule{name: name}
+		switch name {
+		case "required":
+			if hasParam {
+				return nil, fmt.Errorf("invalid rule %q", s)
+			}
+		case "min", "max":
+			var err error
+			r.n, err = strconv.ParseInt(param, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule %q", s)
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", s)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// checkArgsType: check the validate tags of the fields of t, so that wrong
+// rules are found when the function tables are built instead of on a call
+func checkArgsType(t reflect.Type, path string) error {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return checkArgsType(t.Elem(), path)
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Struct {
+			return checkArgsType(t.Elem(), joinPath(path, "0"))
+		}
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			fieldPath := joinPath(path, f.Name)
+			if tag := f.Tag.Get("validate"); tag != "" {
+				rules, err := parseRules(tag)
+				if err != nil {
+					return fmt.Errorf("%s: %w", fieldPath, err)
+				}
+
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				for _, r := range rules {
+					if _, _, ok := ruleSize(reflect.Zero(ft)); r.name != "required" && !ok {
+						return fmt.Errorf("%s: rule %s does not apply to %s", fieldPath, r.name, ft)
+					}
+				}
+			}
+			if err := checkArgsType(f.Type, fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateArgs: check the rules of the arguments in data decoded as the type of args;
+// undecodable data is left to the handler
+func validateArgs(args interface{}, data []byte) error {
+	if args == nil {
+		return nil
+	}
+
+	v := reflect.New(reflect.TypeOf(args))
+	if err := msgpack.Unmarshal(data, v.Interface()); err != nil {
+		return nil
+	}
+
+	return validateValue(v.Elem(), "")
+}
+
+// Validate: check the rules of args, for callers passing arguments to the
+// database without going through a function, e.g. estatectl
+func Validate(args interface{}) error {
+	return validateValue(reflect.ValueOf(args), "")
+}
+
+func validateValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return validateValue(v.Elem(), path)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			fieldPath := joinPath(path, f.Name)
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if err := checkRules(v.Field(i), fieldPath, tag); err != nil {
+					return err
+				}
+			}
+			if err := validateValue(v.Field(i), fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRules: check the rules of tag against the field value v; a
+// *validationError if a rule fails, another error if the tag is wrong
+func checkRules(v reflect.Value, path, tag string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	rules, err := parseRules(tag)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, r := range rules {
+		var ok bool
+		var msg string
+		switch r.name {
+		case "required":
+			ok = !v.IsZero()
+			msg = "must not be empty"
+		case "min", "max":
+			size, unit, sized := ruleSize(v)
+			if !sized {
+				return fmt.Errorf("%s: rule %s does not apply to %s", path, r.name, v.Type())
+			}
+			if r.name == "min" {
+				ok = size >= r.n
+				msg = fmt.Sprintf("must be at least %d%s", r.n, unit)
+			} else {
+				ok = size <= r.n
+				msg = fmt.Sprintf("must be at most %d%s", r.n, unit)
+			}
+		}
+
+		if !ok {
+			return &validationError{field: path, rule: r.name, msg: msg}
+		}
+	}
+
+	return nil
+}
+
+// ruleSize: value compared by min and max and its unit for messages,
+// false if v has no size
+func ruleSize(v reflect.Value) (int64, string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), "", true
+	case reflect.String:
+		return int64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(v.Len()), " elements", true
+	}
+	return 0, "", false
+}
+
+func (e *validationError) Error() string {
+	return e.field + ": " + e.msg
+}
+
+func (e *validationError) response() Response {
+	return Response{
+		Code: EArgsInval,
+		Error: &ErrorDetail{
+			Key:     "args." + e.rule,
+			Message: e.Error(),
+			Field:   e.field,
+		},
+	}
+}
+
+// argsResponse: response to arguments failing validateArgs
+func argsResponse(err error) (interface{}, error) {
+	if v, ok := err.(*validationError); ok {
+		return v.response(), nil
+	}
+	return Response{Code: EUnknown}, err
+}
+
+// validated: check the arguments of handler against the rules of args first
+func validated(args interface{}, handler RequestHandler) RequestHandler {
+	return func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		if err := validateArgs(args, r); err != nil {
+			return argsResponse(err)
+		}
+		return handler(ctx, db, r)
+	}
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name string `validate:"required"`
+}
+
+type testArgs struct {
+	Login    string     `validate:"required"`
+	Password string     `validate:"min=8"`
+	Area     *int32     `validate:"min=0"`
+	Perm     int8       `validate:"min=0,max=63"`
+	Bits     uint8      `validate:"max=63"`
+	Note     *string    `validate:"required"`
+	Items    []testItem `validate:"max=2"`
+}
+
+func TestValidateRules(t *testing.T) {
+	valid := func() testArgs {
+		return testArgs{Login: "ivanov", Password: "password", Items: []testItem{{"a"}}}
+	}
+	minus := int32(-1)
+	empty := ""
+
+	tests := []struct {
+		name   string
+		change func(a *testArgs)
+		key    string // "" if valid
+		field  string
+		msg    string
+	}{
+		{"valid", func(a *testArgs) {}, "", "", ""},
+		{"required", func(a *testArgs) { a.Login = "" }, "args.required", "Login", "Login: must not be empty"},
+		{"min length", func(a *testArgs) { a.Password = "short" }, "args.min", "Password", "Password: must be at least 8 characters"},
+		{"length in characters", func(a *testArgs) { a.Password = "пароль12" }, "", "", ""},
+		{"nil pointer", func(a *testArgs) { a.Area = nil; a.Note = nil }, "", "", ""},
+		{"min of a pointer", func(a *testArgs) { a.Area = &minus }, "args.min", "Area", "Area: must be at least 0"},
+		{"required pointer", func(a *testArgs) { a.Note = &empty }, "args.required", "Note", "Note: must not be empty"},
+		{"max", func(a *testArgs) { a.Perm = 64 }, "args.max", "Perm", "Perm: must be at most 63"},
+		{"min of a signed number", func(a *testArgs) { a.Perm = -1 }, "args.min", "Perm", "Perm: must be at least 0"},
+		{"max of an unsigned number", func(a *testArgs) { a.Bits = 64 }, "args.max", "Bits", "Bits: must be at most 63"},
+		{"max elements", func(a *testArgs) { a.Items = make([]testItem, 3) }, "args.max", "Items", "Items: must be at most 2 elements"},
+		{"element field", func(a *testArgs) { a.Items = []testItem{{"a"}, {""}} }, "args.required", "Items.1.Name", "Items.1.Name: must not be empty"},
+		{"first failing field", func(a *testArgs) { a.Login = ""; a.Password = "" }, "args.required", "Login", "Login: must not be empty"},
+	}
+	for _, tt := range tests {
+		args := valid()
+		tt.change(&args)
+
+		err := validateArgs(testArgs{}, marshal(t, args))
+		if tt.key == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+
+		verr, ok := err.(*validationError)
+		if !ok {
+			t.Errorf("%s: got %v, want a validation error", tt.name, err)
+			continue
+		}
+		detail := verr.response().Error
+		if detail.Key != tt.key || detail.Field != tt.field || detail.Message != tt.msg {
+			t.Errorf("%s: got %+v, want %s %s %q", tt.name, *detail, tt.key, tt.field, tt.msg)
+		}
+	}
+}
+
+func TestCheckArgsType(t *testing.T) {
+	tests := []struct {
+		args interface{}
+		err  string // "" if valid
+	}{
+		{testArgs{}, ""},
+		{struct {
+			A string `validate:"requried"`
+		}{}, `A: unknown rule "requried"`},
+		{struct {
+			A int `validate:"min=x"`
+		}{}, `A: invalid rule "min=x"`},
+		{struct {
+			A int `validate:"max"`
+		}{}, `A: invalid rule "max"`},
+		{struct {
+			A string `validate:"required=1"`
+		}{}, `A: invalid rule "required=1"`},
+		{struct {
+			A bool `validate:"min=1"`
+		}{}, "A: rule min does not apply to bool"},
+		{struct {
+			A *float64 `validate:"max=1"`
+		}{}, "A: rule max does not apply to float64"},
+		{struct {
+			A []struct {
+				B string `validate:"required,min=a"`
+			}
+		}{}, `A.0.B: invalid rule "min=a"`},
+		{struct {
+			A *struct {
+				B string `validate:"mx=1"`
+			}
+		}{}, `A.B: unknown rule "mx=1"`},
+	}
+	for _, tt := range tests {
+		err := checkArgsType(reflect.TypeOf(tt.args), "")
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("%T: got %q, want %q", tt.args, got, tt.err)
+		}
+	}
+
+	// the tags of every function are valid
+	for _, v := range Versions {
+		for _, f := range v.Functions {
+			if f.Args == nil {
+				continue
+			}
+			if err := checkArgsType(reflect.TypeOf(f.Args), ""); err != nil {
+				t.Errorf("%s %s: %v", v.Name, f.Name, err)
+			}
+		}
+	}
+}
+
+func TestNewVersionChecksTags(t *testing.T) {
+	type badArgs struct {
+		Login string `validate:"nonempty"`
+	}
+	handler := func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		return Response{}, nil
+	}
+
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, `Login: unknown rule "nonempty"`) {
+			t.Errorf("newVersion: got panic %q", msg)
+		}
+	}()
+	newVersion("test", []Function{{Name: "bad", Handler: handler, Args: badArgs{}}})
+}
+
+func TestValidatedBadTag(t *testing.T) {
+	type badArgs struct {
+		Login string `validate:"nonempty"`
+	}
+	handler := validated(badArgs{}, func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		t.Error("handler called")
+		return Response{}, nil
+	})
+
+	// a tag missed by newVersion fails the call instead of the server
+	resp, err := handler(context.Background(), nil, marshal(t, badArgs{Login: "ivanov"}))
+	if err == nil || resp.(Response).Code != EUnknown {
+		t.Errorf("got %+v, %v", resp, err)
+	}
+}
+
+func TestValidateFunctionArgs(t *testing.T) {
+	db := newTestStore(t)
+
+	var resp Response
+	call(t, db, V2, "user_create", ArgsFUserCreate{Login: "", Password: testPassword, FirstName: "A", LastName: "B"}, &resp)
+	if resp.Code != EArgsInval || resp.Error == nil || resp.Error.Message != "Login: must not be empty" {
+		t.Errorf("user_create without a login: got %+v %+v", resp, resp.Error)
+	}
+
+	call(t, db, V2, "object_create", ArgsFStructCreate{Token: testToken, Name: "Boiler house", Gid: 1, Permissions: 64}, &resp)
+	if resp.Code != EArgsInval || resp.Error == nil || resp.Error.Field != "Permissions" {
+		t.Errorf("object_create with permissions 64: got %+v %+v", resp, resp.Error)
+	}
+
+	if err := Validate(ArgsFUserCreate{Login: "ivanov", Password: "short", FirstName: "A", LastName: "B"}); err == nil ||
+		err.Error() != "Password: must be at least 8 characters" {
+		t.Errorf("Validate: got %v", err)
+	}
+}
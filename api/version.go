@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Version: API version with its own function table. Versions share the
+// handlers of the functions they do not change.
+type Version struct {
+	Name       string     // path segment, e.g. "v1"
+	Functions  []Function // indexed by function number
+	Deprecated bool       // responses carry a Deprecation header
+	Sunset     string     // HTTP date the version is removed on, optional
+	Successor  string     // name of the version to migrate to, optional
+
+	byName      map[string]*Function
+	describe    sync.Once
+	description RespFDescribe
+}
+
+var (
+	V1       *Version
+	V2       *Version
+	Versions []*Version // all versions, oldest first
+)
+
+// newVersion: version with a copy of the functions, sets up the handlers
+// of the copy so that functions can be shared with other versions; panics
+// if the validate tags of the arguments are wrong
+func newVersion(name string, functions []Function) *Version {
+	v := &Version{
+		Name:      name,
+		Functions: append([]Function(nil), functions...),
+		byName:    make(map[string]*Function, len(functions)),
+	}
+
+	for i, f := range v.Functions {
+		if f.Args != nil {
+			if err := checkArgsType(reflect.TypeOf(f.Args), ""); err != nil {
+				panic(fmt.Sprintf("api: %s %s: %v", name, f.Name, err))
+			}
+		}
+
+		if f.AuthHandler != nil {
+			v.Functions[i].Handler = authenticated(f.Args, f.AuthHandler)
+		} else {
+			v.Functions[i].Handler = validated(f.Args, f.Handler)
+		}
+		v.byName[f.Name] = &v.Functions[i]
+	}
+
+	return v
+}
+
+// override: copy of functions with the ones of the same name replaced
+// by changes, new ones appended
+func override(functions []Function, changes []Function) []Function {
+	result := append([]Function(nil), functions...)
+
+next:
+	for _, c := range changes {
+		for i := range result {
+			if result[i].Name == c.Name {
+				result[i] = c
+				continue next
+			}
+		}
+		result = append(result, c)
+	}
+
+	return result
+}
+
+// LookupVersion: find a version by name, nil if there is none
+func LookupVersion(name string) *Version {
+	for _, v := range Versions {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Lookup: find a function by name, nil if there is none
+func (v *Version) Lookup(name string) *Function {
+	return v.byName[name]
+}
+
+// LookupF: find a function by number, nil if there is none
+func (v *Version) LookupF(num uint8) *Function {
+	if int(num) >= len(v.Functions) {
+		return nil
+	}
+	return &v.Functions[num]
+}
+
+type versionKey struct{}
+
+// WithVersion: context of a call to a function of v
+func WithVersion(ctx context.Context, v *Version) context.Context {
+	return context.WithValue(ctx, versionKey{}, v)
+}
+
+// VersionOf: version of the call, V1 if not set
+func VersionOf(ctx context.Context) *Version {
+	v, ok := ctx.Value(versionKey{}).(*Version)
+	if !ok {
+		return V1
+	}
+	return v
+}
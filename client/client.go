@@ -0,0 +1,144 @@
+// Package client: typed Go client of the estate API
+package client
+
+import (
+	"BastetSoftware/backend/api"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Error: API function returned a non-zero code
+type Error struct {
+	Func   string
+	Code   uint8
+	Detail *api.ErrorDetail // explanation sent by the server, may be nil
+}
+
+func (e *Error) Error() string {
+	name, ok := api.ErrorNames[e.Code]
+	if !ok {
+		name = fmt.Sprint("code ", e.Code)
+	}
+	if e.Detail != nil {
+		name += " (" + e.Detail.Message + ")"
+	}
+	if e.Func == "" {
+		return name
+	}
+	return e.Func + ": " + name
+}
+
+// Is: errors with equal codes match, so errors.Is(err, ErrNoEntry) works
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrExists          = &Error{Code: api.EExists}
+	ErrNoEntry         = &Error{Code: api.ENoEntry}
+	ErrPassWrong       = &Error{Code: api.EPassWrong}
+	ErrNotLoggedIn     = &Error{Code: api.ENotLoggedIn}
+	ErrAccessDenied    = &Error{Code: api.EAccessDenied}
+	ErrTooManyRequests = &Error{Code: api.ETooManyRequests}
+	ErrTooLarge        = &Error{Code: api.ETooLarge}
+	ErrArgsInval       = &Error{Code: api.EArgsInval}
+	ErrNoFun           = &Error{Code: api.ENoFun}
+	ErrUnknown         = &Error{Code: api.EUnknown}
+)
+
+// DefaultVersion: API version the types of the client match
+const DefaultVersion = "v2"
+
+type Client struct {
+	BaseURL string       // server address, e.g. http://localhost:8080
+	Version string       // API version, e.g. "v2"; DefaultVersion if empty
+	Token   string       // session token, set by LogIn
+	HTTP    *http.Client // http.DefaultClient if nil
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Version: DefaultVersion}
+}
+
+// injectToken: set the Token field of args to the client token if it is empty
+func (c *Client) injectToken(args interface{}) {
+	v := reflect.ValueOf(args)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	token := v.Elem().FieldByName("Token")
+	if token.IsValid() && token.Kind() == reflect.String && token.String() == "" {
+		token.SetString(c.Token)
+	}
+}
+
+// call: call API function name; args is a pointer to the arguments or nil,
+// resp is a pointer to the response
+func (c *Client) call(ctx context.Context, name string, args interface{}, resp interface{}) error {
+	var body []byte
+	if args != nil {
+		c.injectToken(args)
+
+		var err error
+		body, err = msgpack.Marshal(args)
+		if err != nil {
+			return err
+		}
+	}
+
+	version := c.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+	url := c.BaseURL + "/api/" + version + "/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/msgpack")
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected HTTP status %s", name, httpResp.Status)
+	}
+
+	// not every response type has a code, so it is read separately; resp is
+	// filled in on errors too, e.g. with the responses of a failed batch
+	var base api.Response
+	err = msgpack.Unmarshal(data, &base)
+	if err != nil {
+		return err
+	}
+	err = msgpack.Unmarshal(data, resp)
+	if err != nil {
+		return err
+	}
+	if base.Code != 0 {
+		return &Error{Func: name, Code: base.Code, Detail: base.Error}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"BastetSoftware/backend/api"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestClientVersion(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		code := uint8(0)
+		if r.URL.Path == "/api/v1/ping" {
+			code = api.ENoFun
+		}
+		b, _ := msgpack.Marshal(api.Response{Code: code})
+		w.Write(b)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	c := New(srv.URL + "/")
+	if err := c.Ping(ctx); err != nil || path != "/api/v2/ping" {
+		t.Errorf("default version: called %s, %v", path, err)
+	}
+
+	c.Version = "v1"
+	if err := c.Ping(ctx); !errors.Is(err, ErrNoFun) || path != "/api/v1/ping" {
+		t.Errorf("version v1: called %s, %v", path, err)
+	}
+
+	c = &Client{BaseURL: srv.URL}
+	if err := c.Ping(ctx); err != nil || path != "/api/"+DefaultVersion+"/ping" {
+		t.Errorf("empty version: called %s, %v", path, err)
+	}
+}
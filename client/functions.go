@@ -0,0 +1,155 @@
+package client
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"context"
+)
+
+/* Service */
+
+func (c *Client) Ping(ctx context.Context) error {
+	var resp api.Response
+	return c.call(ctx, "ping", nil, &resp)
+}
+
+// Batch: run several functions in one request; if an atomic batch fails, the
+// responses up to the failed one are returned together with its error
+func (c *Client) Batch(ctx context.Context, args api.ArgsFBatch) (api.RespFBatch, error) {
+	var resp api.RespFBatch
+	err := c.call(ctx, "batch", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) Describe(ctx context.Context) (api.RespFDescribe, error) {
+	var resp api.RespFDescribe
+	err := c.call(ctx, "describe", nil, &resp)
+	return resp, err
+}
+
+/* Users */
+
+func (c *Client) CreateUser(ctx context.Context, args api.ArgsFUserCreate) error {
+	var resp api.Response
+	return c.call(ctx, "user_create", &args, &resp)
+}
+
+// LogIn: open a session, the client uses its token for subsequent calls
+func (c *Client) LogIn(ctx context.Context, args api.ArgsFLogIn) (api.RespFLogIn, error) {
+	var resp api.RespFLogIn
+	err := c.call(ctx, "user_log_in", &args, &resp)
+	if err == nil {
+		c.Token = resp.Token
+	}
+	return resp, err
+}
+
+// LogOut: close the session and forget its token
+func (c *Client) LogOut(ctx context.Context, args api.ArgsFLogOut) error {
+	var resp api.Response
+	err := c.call(ctx, "user_log_out", &args, &resp)
+	if err == nil && args.Token == c.Token {
+		c.Token = ""
+	}
+	return err
+}
+
+func (c *Client) GetUserInfo(ctx context.Context, args api.ArgsFUserInfo) (api.RespFUserInfo, error) {
+	var resp api.RespFUserInfo
+	err := c.call(ctx, "user_get_info", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) EditUser(ctx context.Context, args api.ArgsFUserEdit) error {
+	var resp api.Response
+	return c.call(ctx, "user_edit", &args, &resp)
+}
+
+func (c *Client) SetManagesGroups(ctx context.Context, args api.ArgsFUserSetManagesGroups) error {
+	var resp api.Response
+	return c.call(ctx, "user_set_manages_groups", &args, &resp)
+}
+
+func (c *Client) ListUserGroups(ctx context.Context, args api.ArgsFUserListGroups) (api.RespFUserListGroups, error) {
+	var resp api.RespFUserListGroups
+	err := c.call(ctx, "user_list_groups", &args, &resp)
+	return resp, err
+}
+
+/* Groups */
+
+func (c *Client) CreateGroup(ctx context.Context, args api.ArgsFGroupCreateRemove) error {
+	var resp api.Response
+	return c.call(ctx, "group_create", &args, &resp)
+}
+
+func (c *Client) RemoveGroup(ctx context.Context, args api.ArgsFGroupCreateRemove) error {
+	var resp api.Response
+	return c.call(ctx, "group_remove", &args, &resp)
+}
+
+func (c *Client) AddRemoveGroupUser(ctx context.Context, args api.ArgsFGroupAddRemoveUser) error {
+	var resp api.Response
+	return c.call(ctx, "group_add_remove_user", &args, &resp)
+}
+
+func (c *Client) GetGroupInfo(ctx context.Context, args api.ArgsFGroupGetInfo) (api.RespFGroupGetInfo, error) {
+	var resp api.RespFGroupGetInfo
+	err := c.call(ctx, "group_get_info", &args, &resp)
+	return resp, err
+}
+
+/* Objects */
+
+func (c *Client) CreateObject(ctx context.Context, args api.ArgsFStructCreate) (api.RespFStructCreate, error) {
+	var resp api.RespFStructCreate
+	err := c.call(ctx, "object_create", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) GetObjectInfo(ctx context.Context, args api.ArgsFStructInfo) (api.RespFStructInfoV2, error) {
+	var resp api.RespFStructInfoV2
+	err := c.call(ctx, "object_get_info", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) FindObjects(ctx context.Context, args database.ArgsFStructFind) (api.RespFStructFind, error) {
+	var resp api.RespFStructFind
+	err := c.call(ctx, "find_object", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) DeleteObject(ctx context.Context, args api.ArgsFDeleteStruct) error {
+	var resp api.Response
+	return c.call(ctx, "object_delete", &args, &resp)
+}
+
+func (c *Client) EditObject(ctx context.Context, args api.ArgsFStructEdit) error {
+	var resp api.Response
+	return c.call(ctx, "object_change", &args, &resp)
+}
+
+/* Tasks */
+
+func (c *Client) CreateTask(ctx context.Context, args api.ArgsFTaskCreate) (api.RespFTaskCreate, error) {
+	var resp api.RespFTaskCreate
+	err := c.call(ctx, "task_create", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) RemoveTask(ctx context.Context, args api.ArgsFTaskRemove) error {
+	var resp api.Response
+	return c.call(ctx, "task_remove", &args, &resp)
+}
+
+func (c *Client) GetTaskInfo(ctx context.Context, args api.ArgsFTaskGetInfo) (api.RespFTaskGetInfo, error) {
+	var resp api.RespFTaskGetInfo
+	err := c.call(ctx, "task_get_info", &args, &resp)
+	return resp, err
+}
+
+func (c *Client) SearchTasks(ctx context.Context, args api.ArgsFTaskSearch) (api.RespFTaskSearch, error) {
+	var resp api.RespFTaskSearch
+	err := c.call(ctx, "task_search", &args, &resp)
+	return resp, err
+}
@@ -0,0 +1,88 @@
+// apidoc: generate the API schema reference from api.Describe
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+func writeFields(w io.Writer, prefix string, fields []api.FieldDesc) {
+	for _, f := range fields {
+		nullable := ""
+		if f.Nullable {
+			nullable = "yes"
+		}
+		fmt.Fprintf(w, "| %s%s | %s | %s | %s |\n", prefix, f.Name, f.Type, nullable, f.Rules)
+		writeFields(w, prefix+f.Name+".", f.Fields)
+	}
+}
+
+func writeTable(w io.Writer, title string, fields []api.FieldDesc) {
+	fmt.Fprintf(w, "##### %s\n\n", title)
+	if fields == nil {
+		fmt.Fprint(w, "None\n\n")
+		return
+	}
+	fmt.Fprint(w, "| field | type | nullable | rules |\n|-------|------|----------|-------|\n")
+	writeFields(w, "", fields)
+	fmt.Fprintln(w)
+}
+
+func main() {
+	out := flag.String("o", "", "output file (default: stdout)")
+	version := flag.String("version", "v1", "API version")
+	flag.Parse()
+
+	v := api.LookupVersion(*version)
+	if v == nil {
+		log.Fatalf("unknown API version %q", *version)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	desc := v.Describe()
+
+	fmt.Fprintf(bw, "# API schema, %s\n\n", v.Name)
+	fmt.Fprint(bw, "Generated from the Go types by `go generate ./api`, do not edit.\n\n")
+
+	fmt.Fprint(bw, "## Error codes\n\n| name | code | key |\n|------|:----:|-----|\n")
+	for _, e := range desc.Errors {
+		fmt.Fprintf(bw, "| %s | %d | %s |\n", e.Name, e.Code, e.Key)
+	}
+	fmt.Fprintln(bw)
+
+	fmt.Fprintf(bw, "Besides its own errors, any function can fail with: %s.\n\n", strings.Join(desc.CommonErrors, ", "))
+
+	fmt.Fprint(bw, "## Functions\n\n")
+	for _, f := range desc.Functions {
+		fmt.Fprintf(bw, "### %s\n\nFunction number: %d\n\n", f.Name, f.Number)
+		if f.Auth {
+			fmt.Fprint(bw, "Requires a session token.\n\n")
+		}
+		writeTable(bw, "Request args", f.Args)
+		writeTable(bw, "Response data", f.Resp)
+
+		fmt.Fprint(bw, "##### Possible errors\n\n")
+		if len(f.Errors) == 0 {
+			fmt.Fprint(bw, "None\n\n")
+		} else {
+			fmt.Fprintf(bw, "%s\n\n", strings.Join(f.Errors, ", "))
+		}
+	}
+}
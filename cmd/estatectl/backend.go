@@ -0,0 +1,284 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/client"
+	"BastetSoftware/backend/database"
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// backend: operations of estatectl, done either on the database or through the API
+type backend interface {
+	CreateUser(args api.ArgsFUserCreate) error
+	SetManagesGroups(login string, value bool) error
+
+	CreateGroup(name string) error
+	RemoveGroup(name string) error
+	GroupAddRemoveUser(group string, login string, add bool) error
+
+	ListObjects(limit int16, offset int16) ([]database.StructInfo, error)
+	CreateObject(args api.ArgsFStructCreate) (int64, error)
+	DeleteObject(id int64) error
+
+	ListTasks(limit int16, offset int16) ([]database.Task, error)
+	CreateTask(args api.ArgsFTaskCreate) (int64, error)
+	DeleteTask(id int64) error
+
+	MigrateUp() ([]database.Migration, error)
+	MigrateDown() (*database.Migration, error)
+	Migrations() ([]database.MigrationState, error)
+}
+
+/*
+ * Direct database access, no authentication; the arguments are checked
+ * against the same rules as the API functions
+ */
+
+type dbBackend struct {
+	db database.Store
+}
+
+func (b dbBackend) CreateUser(args api.ArgsFUserCreate) error {
+	if err := api.Validate(args); err != nil {
+		return err
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(args.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.db.RegisterUser(context.Background(), &database.UserInfo{
+		Login:      args.Login,
+		PassHash:   passHash,
+		FirstName:  args.FirstName,
+		LastName:   args.LastName,
+		Patronymic: args.Patronymic,
+	})
+	return err
+}
+
+func (b dbBackend) SetManagesGroups(login string, value bool) error {
+	user, err := b.db.FindUserInfo(context.Background(), login)
+	if err != nil {
+		return err
+	}
+	return b.db.UserSetManagesGroups(context.Background(), user.Id, value)
+}
+
+func (b dbBackend) CreateGroup(name string) error {
+	if err := api.Validate(api.ArgsFGroupCreateRemove{Name: name}); err != nil {
+		return err
+	}
+
+	_, err := b.db.CreateGroup(context.Background(), name)
+	return err
+}
+
+func (b dbBackend) RemoveGroup(name string) error {
+	group, err := b.db.FindGroup(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	return b.db.RemoveGroup(context.Background(), group.Id)
+}
+
+func (b dbBackend) GroupAddRemoveUser(group string, login string, add bool) error {
+	g, err := b.db.FindGroup(context.Background(), group)
+	if err != nil {
+		return err
+	}
+	user, err := b.db.FindUserInfo(context.Background(), login)
+	if err != nil {
+		return err
+	}
+
+	if add {
+		return b.db.GroupAddUser(context.Background(), user.Id, g.Id)
+	}
+	return b.db.GroupRemoveUser(context.Background(), user.Id, g.Id)
+}
+
+func (b dbBackend) ListObjects(limit int16, offset int16) ([]database.StructInfo, error) {
+	args := database.ArgsFStructFind{Limit: limit, Offset: offset}
+	if err := api.Validate(args); err != nil {
+		return nil, err
+	}
+	return b.db.FindStructures(context.Background(), args)
+}
+
+func (b dbBackend) CreateObject(args api.ArgsFStructCreate) (int64, error) {
+	if err := api.Validate(args); err != nil {
+		return 0, err
+	}
+
+	strct := database.StructInfo{
+		Name:        args.Name,
+		Description: args.Description,
+		District:    args.District,
+		Region:      args.Region,
+		Address:     args.Address,
+		Type:        args.Type,
+		State:       args.State,
+		Area:        args.Area,
+		Owner:       args.Owner,
+		Actual_user: args.Actual_user,
+		Gid:         args.Gid,
+		Permissions: args.Permissions,
+	}
+	err := b.db.AddStruct(context.Background(), &strct)
+	return strct.Id, err
+}
+
+func (b dbBackend) DeleteObject(id int64) error {
+	return b.db.DeleteStruct(context.Background(), id)
+}
+
+func (b dbBackend) ListTasks(limit int16, offset int16) ([]database.Task, error) {
+	if err := api.Validate(api.ArgsFTaskSearch{Limit: limit, Offset: offset}); err != nil {
+		return nil, err
+	}
+
+	tasks, err := b.db.FilterTasks(context.Background(), &database.TaskFilter{Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]database.Task, len(tasks))
+	for i, task := range tasks {
+		list[i] = *task
+	}
+	return list, nil
+}
+
+func (b dbBackend) CreateTask(args api.ArgsFTaskCreate) (int64, error) {
+	if err := api.Validate(args); err != nil {
+		return 0, err
+	}
+
+	return b.db.CreateTask(context.Background(), &database.Task{
+		Name:        args.Name,
+		Description: args.Description,
+		Deadline:    args.Deadline,
+		Status:      args.Status,
+		Object:      args.Object,
+		Maintainer:  args.Maintainer,
+		Gid:         args.Gid,
+		Permissions: args.Permissions,
+	})
+}
+
+func (b dbBackend) DeleteTask(id int64) error {
+	return b.db.RemoveTask(context.Background(), id)
+}
+
+// sqlStore: the database of migrations
+func (b dbBackend) sqlStore() (*database.SQLStore, error) {
+	s, ok := b.db.(*database.SQLStore)
+	if !ok {
+		return nil, fmt.Errorf("the database has no migrations")
+	}
+	return s, nil
+}
+
+func (b dbBackend) MigrateUp() ([]database.Migration, error) {
+	s, err := b.sqlStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.MigrateUp(context.Background())
+}
+
+func (b dbBackend) MigrateDown() (*database.Migration, error) {
+	s, err := b.sqlStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.MigrateDown(context.Background())
+}
+
+func (b dbBackend) Migrations() ([]database.MigrationState, error) {
+	s, err := b.sqlStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.Migrations(context.Background())
+}
+
+/*
+ * API access, authenticated by the client token
+ */
+
+type apiBackend struct {
+	c *client.Client
+}
+
+func (b apiBackend) CreateUser(args api.ArgsFUserCreate) error {
+	return b.c.CreateUser(context.Background(), args)
+}
+
+func (b apiBackend) SetManagesGroups(login string, value bool) error {
+	return b.c.SetManagesGroups(context.Background(), api.ArgsFUserSetManagesGroups{Login: login, Value: value})
+}
+
+func (b apiBackend) CreateGroup(name string) error {
+	return b.c.CreateGroup(context.Background(), api.ArgsFGroupCreateRemove{Name: name})
+}
+
+func (b apiBackend) RemoveGroup(name string) error {
+	return b.c.RemoveGroup(context.Background(), api.ArgsFGroupCreateRemove{Name: name})
+}
+
+func (b apiBackend) GroupAddRemoveUser(group string, login string, add bool) error {
+	return b.c.AddRemoveGroupUser(context.Background(), api.ArgsFGroupAddRemoveUser{
+		Group:  group,
+		Login:  login,
+		Action: add,
+	})
+}
+
+func (b apiBackend) ListObjects(limit int16, offset int16) ([]database.StructInfo, error) {
+	resp, err := b.c.FindObjects(context.Background(), database.ArgsFStructFind{Limit: limit, Offset: offset})
+	return resp.Structures, err
+}
+
+func (b apiBackend) CreateObject(args api.ArgsFStructCreate) (int64, error) {
+	resp, err := b.c.CreateObject(context.Background(), args)
+	return resp.Id, err
+}
+
+func (b apiBackend) DeleteObject(id int64) error {
+	return b.c.DeleteObject(context.Background(), api.ArgsFDeleteStruct{Id: id})
+}
+
+func (b apiBackend) ListTasks(limit int16, offset int16) ([]database.Task, error) {
+	resp, err := b.c.SearchTasks(context.Background(), api.ArgsFTaskSearch{Limit: limit, Offset: offset})
+	return resp.Tasks, err
+}
+
+func (b apiBackend) CreateTask(args api.ArgsFTaskCreate) (int64, error) {
+	resp, err := b.c.CreateTask(context.Background(), args)
+	return resp.Id, err
+}
+
+func (b apiBackend) DeleteTask(id int64) error {
+	return b.c.RemoveTask(context.Background(), api.ArgsFTaskRemove{Id: id})
+}
+
+var errNeedsDatabase = errors.New("migrations need direct database access, run without -api")
+
+func (b apiBackend) MigrateUp() ([]database.Migration, error) {
+	return nil, errNeedsDatabase
+}
+
+func (b apiBackend) MigrateDown() (*database.Migration, error) {
+	return nil, errNeedsDatabase
+}
+
+func (b apiBackend) Migrations() ([]database.MigrationState, error) {
+	return nil, errNeedsDatabase
+}
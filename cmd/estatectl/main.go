@@ -0,0 +1,396 @@
+// estatectl: administrative tool working on the database or through the API
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/client"
+	"BastetSoftware/backend/config"
+	"BastetSoftware/backend/database"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+type command struct {
+	args string // usage of the arguments
+	run  func(b backend, args []string) error
+}
+
+var commands = map[string]command{
+	"user create": {
+		"LOGIN PASSWORD FIRST_NAME LAST_NAME [PATRONYMIC]",
+		func(b backend, args []string) error {
+			if len(args) < 4 || len(args) > 5 {
+				return errUsage
+			}
+			u := api.ArgsFUserCreate{
+				Login:     args[0],
+				Password:  args[1],
+				FirstName: args[2],
+				LastName:  args[3],
+			}
+			if len(args) == 5 {
+				u.Patronymic = args[4]
+			}
+			return b.CreateUser(u)
+		},
+	},
+	"user grant-groups": {
+		"LOGIN",
+		func(b backend, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			return b.SetManagesGroups(args[0], true)
+		},
+	},
+	"user revoke-groups": {
+		"LOGIN",
+		func(b backend, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			return b.SetManagesGroups(args[0], false)
+		},
+	},
+
+	"group create": {
+		"NAME",
+		func(b backend, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			return b.CreateGroup(args[0])
+		},
+	},
+	"group remove": {
+		"NAME",
+		func(b backend, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			return b.RemoveGroup(args[0])
+		},
+	},
+	"group add-user": {
+		"GROUP LOGIN",
+		func(b backend, args []string) error {
+			if len(args) != 2 {
+				return errUsage
+			}
+			return b.GroupAddRemoveUser(args[0], args[1], true)
+		},
+	},
+	"group remove-user": {
+		"GROUP LOGIN",
+		func(b backend, args []string) error {
+			if len(args) != 2 {
+				return errUsage
+			}
+			return b.GroupAddRemoveUser(args[0], args[1], false)
+		},
+	},
+
+	"object list": {
+		"[-limit N] [-offset N]",
+		func(b backend, args []string) error {
+			fs := newFlagSet("object list")
+			limit := fs.Int("limit", 100, "maximum number of objects")
+			offset := fs.Int("offset", 0, "number of objects to skip")
+			if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+				return errUsage
+			}
+			if err := checkRange("limit", *limit, math.MinInt16, math.MaxInt16); err != nil {
+				return err
+			}
+			if err := checkRange("offset", *offset, math.MinInt16, math.MaxInt16); err != nil {
+				return err
+			}
+
+			objects, err := b.ListObjects(int16(*limit), int16(*offset))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tTYPE\tADDRESS\tAREA\tGID\tPERMISSIONS")
+			for _, o := range objects {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\n", o.Id, o.Name, o.Type, o.Address, o.Area, o.Gid, o.Permissions)
+			}
+			return w.Flush()
+		},
+	},
+	"object create": {
+		"-name NAME -gid GID [-description S] [-district S] [-region S] [-address S] [-type S] [-state S] [-area N] [-owner S] [-actual-user S] [-permissions N]",
+		func(b backend, args []string) error {
+			var o api.ArgsFStructCreate
+			fs := newFlagSet("object create")
+			fs.StringVar(&o.Name, "name", "", "")
+			fs.StringVar(&o.Description, "description", "", "")
+			fs.StringVar(&o.District, "district", "", "")
+			fs.StringVar(&o.Region, "region", "", "")
+			fs.StringVar(&o.Address, "address", "", "")
+			fs.StringVar(&o.Type, "type", "", "")
+			fs.StringVar(&o.State, "state", "", "")
+			area := fs.Int("area", 0, "")
+			fs.StringVar(&o.Owner, "owner", "", "")
+			fs.StringVar(&o.Actual_user, "actual-user", "", "")
+			fs.Int64Var(&o.Gid, "gid", 0, "")
+			permissions := fs.Int("permissions", 0, "")
+			if err := fs.Parse(args); err != nil || fs.NArg() != 0 || o.Name == "" || o.Gid == 0 {
+				return errUsage
+			}
+			if err := checkRange("area", *area, math.MinInt32, math.MaxInt32); err != nil {
+				return err
+			}
+			if err := checkRange("permissions", *permissions, math.MinInt8, math.MaxInt8); err != nil {
+				return err
+			}
+			o.Area = int32(*area)
+			o.Permissions = int8(*permissions)
+
+			id, err := b.CreateObject(o)
+			if err != nil {
+				return err
+			}
+			fmt.Println(id)
+			return nil
+		},
+	},
+	"object delete": {
+		"ID",
+		func(b backend, args []string) error {
+			id, err := parseId(args)
+			if err != nil {
+				return err
+			}
+			return b.DeleteObject(id)
+		},
+	},
+
+	"task list": {
+		"[-limit N] [-offset N]",
+		func(b backend, args []string) error {
+			fs := newFlagSet("task list")
+			limit := fs.Int("limit", 100, "maximum number of tasks")
+			offset := fs.Int("offset", 0, "number of tasks to skip")
+			if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+				return errUsage
+			}
+			if err := checkRange("limit", *limit, math.MinInt16, math.MaxInt16); err != nil {
+				return err
+			}
+			if err := checkRange("offset", *offset, math.MinInt16, math.MaxInt16); err != nil {
+				return err
+			}
+
+			tasks, err := b.ListTasks(int16(*limit), int16(*offset))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tSTATUS\tDEADLINE\tOBJECT\tMAINTAINER\tGID")
+			for _, t := range tasks {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%d\n", t.Id, t.Name, t.Status, t.Deadline, t.Object, t.Maintainer, t.Gid)
+			}
+			return w.Flush()
+		},
+	},
+	"task create": {
+		"-name NAME -object ID -maintainer UID -gid GID [-description S] [-deadline UNIX_TIME] [-status S] [-permissions N]",
+		func(b backend, args []string) error {
+			var t api.ArgsFTaskCreate
+			fs := newFlagSet("task create")
+			fs.StringVar(&t.Name, "name", "", "")
+			fs.StringVar(&t.Description, "description", "", "")
+			fs.Int64Var(&t.Deadline, "deadline", 0, "")
+			fs.StringVar(&t.Status, "status", "", "")
+			fs.Int64Var(&t.Object, "object", 0, "")
+			fs.Int64Var(&t.Maintainer, "maintainer", 0, "")
+			fs.Int64Var(&t.Gid, "gid", 0, "")
+			permissions := fs.Int("permissions", 0, "")
+			if err := fs.Parse(args); err != nil || fs.NArg() != 0 ||
+				t.Name == "" || t.Object == 0 || t.Maintainer == 0 || t.Gid == 0 {
+				return errUsage
+			}
+			if err := checkRange("permissions", *permissions, 0, math.MaxUint8); err != nil {
+				return err
+			}
+			t.Permissions = uint8(*permissions)
+
+			id, err := b.CreateTask(t)
+			if err != nil {
+				return err
+			}
+			fmt.Println(id)
+			return nil
+		},
+	},
+	"task delete": {
+		"ID",
+		func(b backend, args []string) error {
+			id, err := parseId(args)
+			if err != nil {
+				return err
+			}
+			return b.DeleteTask(id)
+		},
+	},
+
+	"migrate up": {
+		"",
+		func(b backend, args []string) error {
+			if len(args) != 0 {
+				return errUsage
+			}
+
+			done, err := b.MigrateUp()
+			for _, m := range done {
+				fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+			}
+			if err == nil && len(done) == 0 {
+				fmt.Println("schema is up to date")
+			}
+			return err
+		},
+	},
+	"migrate down": {
+		"",
+		func(b backend, args []string) error {
+			if len(args) != 0 {
+				return errUsage
+			}
+
+			m, err := b.MigrateDown()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+			return nil
+		},
+	},
+	"migrate status": {
+		"",
+		func(b backend, args []string) error {
+			if len(args) != 0 {
+				return errUsage
+			}
+
+			migrations, err := b.Migrations()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+			for _, m := range migrations {
+				applied := "pending"
+				if m.Applied {
+					applied = m.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", m.Version, m.Name, applied)
+			}
+			return w.Flush()
+		},
+	},
+}
+
+var errUsage = fmt.Errorf("invalid arguments")
+
+// newFlagSet: flag set of a command, errors are reported with the command usage
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// checkRange: error if the value of a flag does not fit the type it is converted to
+func checkRange(name string, value int, min int, max int) error {
+	if value < min || value > max {
+		return fmt.Errorf("-%s must be between %d and %d", name, min, max)
+	}
+	return nil
+}
+
+func parseId(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	return strconv.ParseInt(args[0], 10, 64)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-api URL [-token TOKEN]] COMMAND ARGS...\n\n", os.Args[0])
+	fmt.Fprint(out, "Without -api, the database is used directly, configured like the server (-config, -db-*, environment).\n\n")
+	fmt.Fprint(out, "Options:\n")
+	flag.PrintDefaults()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprint(out, "\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s %s\n", name, commands[name].args)
+	}
+}
+
+func main() {
+	apiURL := flag.String("api", "", "API server address, e.g. http://localhost:8080")
+	token := flag.String("token", os.Getenv("ESTATE_TOKEN"), "session token for -api (default $ESTATE_TOKEN)")
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0) + " " + flag.Arg(1)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	var b backend
+	if *apiURL != "" {
+		c := client.New(*apiURL)
+		c.Token = *token
+		b = apiBackend{c: c}
+	} else {
+		cfg, err := flags.Load()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		db, err := database.Open(cfg.Database)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		b = dbBackend{db: db}
+	}
+
+	err := cmd.run(b, flag.Args()[2:])
+	if err == errUsage {
+		fmt.Fprintf(os.Stderr, "usage: %s %s %s\n", os.Args[0], name, cmd.args)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
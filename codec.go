@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec: wire format of request and response bodies
+type codec int
+
+const (
+	codecMsgpack codec = iota // default
+	codecJSON
+)
+
+var codecContentTypes = [...]string{
+	codecMsgpack: "application/msgpack",
+	codecJSON:    "application/json",
+}
+
+func (c codec) ContentType() string {
+	return codecContentTypes[c]
+}
+
+// parseCodec: map a media type to a codec, ok is false for unknown types
+func parseCodec(mediaType string) (c codec, ok bool) {
+	switch mediaType {
+	case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+		return codecMsgpack, true
+	case "application/json":
+		return codecJSON, true
+	}
+	return codecMsgpack, false
+}
+
+// requestCodec: codec of the request body, chosen by Content-Type
+func requestCodec(r *http.Request) codec {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return codecMsgpack
+	}
+	c, _ := parseCodec(mediaType)
+	return c
+}
+
+// responseCodec: codec of the response body, the one with the highest
+// quality in Accept; falls back to the request codec unless Accept
+// refuses it with q=0
+func responseCodec(r *http.Request, reqCodec codec) codec {
+	best, bestQ := reqCodec, 0.0
+	refused := make(map[codec]bool)
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		c, ok := parseCodec(mediaType)
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			refused[c] = true
+		} else if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	if bestQ == 0 && refused[best] {
+		// the other codec
+		if best == codecJSON {
+			return codecMsgpack
+		}
+		return codecJSON
+	}
+	return best
+}
+
+// errTrailingData: a JSON body has more than one value
+var errTrailingData = errors.New("unexpected data after the JSON value")
+
+// decodeArgs: convert a request body to the msgpack encoding expected by api handlers
+func (c codec) decodeArgs(body []byte) ([]byte, error) {
+	if c == codecMsgpack || len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // keep integers exact
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errTrailingData
+	}
+
+	return msgpack.Marshal(jsonToMsgpack(v))
+}
+
+// jsonToMsgpack: replace json.Number values with integers where possible,
+// so that they decode into integer fields of Args* structs
+func jsonToMsgpack(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonToMsgpack(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonToMsgpack(e)
+		}
+	}
+	return v
+}
+
+// encode: marshal a response value
+func (c codec) encode(v interface{}) ([]byte, error) {
+	if c == codecJSON {
+		return json.Marshal(v)
+	}
+	return msgpack.Marshal(v)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestResponseCodec(t *testing.T) {
+	tests := []struct {
+		accept string
+		req    codec
+		want   codec
+	}{
+		{"", codecMsgpack, codecMsgpack},
+		{"", codecJSON, codecJSON},
+		{"*/*", codecJSON, codecJSON},
+		{"application/json", codecMsgpack, codecJSON},
+		{"application/msgpack", codecJSON, codecMsgpack},
+		{"text/html, application/json", codecMsgpack, codecJSON},
+		{"application/json;q=0.5, application/msgpack", codecJSON, codecMsgpack},
+		{"application/msgpack;q=0.1, application/json;q=0.9", codecMsgpack, codecJSON},
+		{"application/json;q=0", codecJSON, codecMsgpack},
+		{"application/msgpack;q=0", codecMsgpack, codecJSON},
+		{"application/json;q=0, application/msgpack;q=0.5", codecJSON, codecMsgpack},
+		{"application/json;q=x", codecMsgpack, codecMsgpack},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/ping", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := responseCodec(r, tt.req); got != tt.want {
+			t.Errorf("Accept %q, request %s: got %s, want %s", tt.accept, tt.req.ContentType(), got.ContentType(), tt.want.ContentType())
+		}
+	}
+}
+
+func TestDecodeArgs(t *testing.T) {
+	want, err := msgpack.Marshal(map[string]interface{}{"Login": "ivanov", "Id": int64(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body string
+		ok   bool
+	}{
+		{`{"Login": "ivanov", "Id": 5}`, true},
+		{" {\"Login\": \"ivanov\", \"Id\": 5}\n", true},
+		{`{"Login": "ivanov", "Id": 5}xyz`, false},
+		{`{"Login": "ivanov", "Id": 5} {}`, false},
+		{`{"Login": "ivanov"`, false},
+	}
+	for _, tt := range tests {
+		got, err := codecJSON.decodeArgs([]byte(tt.body))
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%q: no error", tt.body)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.body, err)
+			continue
+		}
+		var gotV, wantV interface{}
+		msgpack.Unmarshal(got, &gotV)
+		msgpack.Unmarshal(want, &wantV)
+		if !reflect.DeepEqual(gotV, wantV) {
+			t.Errorf("%q: got %v, want %v", tt.body, gotV, wantV)
+		}
+	}
+
+	if got, err := codecJSON.decodeArgs([]byte("  ")); err != nil || len(bytes.TrimSpace(got)) != 0 {
+		t.Errorf("empty body: got %q, %v", got, err)
+	}
+}
+
+// callCodec: call an API function with args encoded by c, the response
+// decoded into generic JSON values
+func callCodec(t *testing.T, h http.Handler, name string, c codec, args interface{}) map[string]interface{} {
+	t.Helper()
+	body, err := c.encode(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/"+name, bytes.NewReader(body))
+	r.Header.Set("Content-Type", c.ContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if ct := w.Header().Get("Content-Type"); ct != c.ContentType() {
+		t.Fatalf("%s: Content-Type %q, want %q", name, ct, c.ContentType())
+	}
+	var v interface{}
+	if c == codecJSON {
+		err = json.Unmarshal(w.Body.Bytes(), &v)
+	} else {
+		err = msgpack.Unmarshal(w.Body.Bytes(), &v)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// numbers of both formats as JSON numbers
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	db, err := devStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Db = db
+	h := apiHandler(api.V2, "/api/")
+
+	calls := []struct {
+		name string
+		args interface{}
+		code float64
+	}{
+		{"user_log_in", api.ArgsFLogIn{Login: "ivanov", Password: "wrong"}, float64(api.EPassWrong)},
+		{"user_log_in", map[string]interface{}{"Login": "ivanov", "Bogus": 1}, float64(api.EArgsInval)},
+		{"user_get_info", api.ArgsFUserInfo{Token: "none", Login: "ivanov"}, float64(api.ENotLoggedIn)},
+		{"ping", struct{}{}, 0},
+	}
+	for _, call := range calls {
+		fromJSON := callCodec(t, h, call.name, codecJSON, call.args)
+		fromMsgpack := callCodec(t, h, call.name, codecMsgpack, call.args)
+		if !reflect.DeepEqual(fromJSON, fromMsgpack) {
+			t.Errorf("%s: JSON response %v, msgpack response %v", call.name, fromJSON, fromMsgpack)
+		}
+		if fromJSON["Code"] != call.code {
+			t.Errorf("%s: Code %v, want %v", call.name, fromJSON["Code"], call.code)
+		}
+	}
+
+	// a successful response with fields
+	login := callCodec(t, h, "user_log_in", codecJSON, api.ArgsFLogIn{Login: "ivanov", Password: devPassword})
+	if login["Code"] != 0.0 || login["Token"] == "" {
+		t.Fatalf("user_log_in: got %v", login)
+	}
+	token := login["Token"].(string)
+	args := api.ArgsFUserInfo{Token: token, Login: "petrova"}
+	fromJSON := callCodec(t, h, "user_get_info", codecJSON, args)
+	fromMsgpack := callCodec(t, h, "user_get_info", codecMsgpack, args)
+	if !reflect.DeepEqual(fromJSON, fromMsgpack) || fromJSON["Login"] != "petrova" {
+		t.Errorf("user_get_info: JSON response %v, msgpack response %v", fromJSON, fromMsgpack)
+	}
+}
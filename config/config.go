@@ -0,0 +1,200 @@
+// Package config: server configuration from a file, the environment and flags.
+// Later sources override earlier ones: defaults, file, environment, flags.
+package config
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"BastetSoftware/backend/ratelimit"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+type Config struct {
+	Server    Server
+	Database  database.Config
+	Session   Session
+	API       API
+	CORS      CORS
+	RateLimit RateLimit
+	Log       Log
+}
+
+type Server struct {
+	Listen          string // host:port
+	TLSCert         string // certificate file, TLS is enabled if set
+	TLSKey          string // private key file
+	RequestTimeout  time.Duration
+	RequestTimeouts map[string]time.Duration // per-function, override RequestTimeout
+	ShutdownTimeout time.Duration
+	MaxBodySize     int64    // bytes, larger requests fail with ETooLarge
+	TrustedProxies  []string // addresses or CIDR ranges of proxies setting X-Forwarded-For
+}
+
+type Session struct {
+	Lifetime time.Duration
+}
+
+type API struct {
+	Deprecated []string // versions announced as deprecated, e.g. "v1"
+}
+
+type CORS struct {
+	Origins     []string // allowed origins, "*" for any
+	Methods     []string // allowed request methods
+	Headers     []string // allowed request headers
+	Credentials bool     // allow cookies and HTTP authentication
+	MaxAge      time.Duration
+}
+
+type RateLimit struct {
+	LoginIP ratelimit.Limit // authentication calls per client IP
+	Login   ratelimit.Limit // authentication calls per login
+	Token   ratelimit.Limit // other calls per session token (per client IP without one)
+	Store   string          // "memory" or "database"
+}
+
+type Log struct {
+	File   string // append log to this file instead of stderr
+	Level  string // "debug", "info", "warn" or "error"
+	Format string // "json" or "text"
+}
+
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Listen:          ":8080",
+			RequestTimeout:  30 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			MaxBodySize:     1 << 20,
+		},
+		Database: database.Config{
+			Driver:       "mysql",
+			Addr:         "127.0.0.1:3306",
+			Name:         "estate",
+			MaxIdleConns: 2,
+		},
+		Session: Session{
+			Lifetime: 2 * 24 * time.Hour,
+		},
+		CORS: CORS{
+			Origins: []string{"*"},
+			Methods: []string{"POST", "OPTIONS"},
+			Headers: []string{"Content-Type", "Accept"},
+			MaxAge:  10 * time.Minute,
+		},
+		RateLimit: RateLimit{
+			LoginIP: ratelimit.Limit{N: 20, Window: time.Minute},
+			Login:   ratelimit.Limit{N: 5, Window: time.Minute},
+			Token:   ratelimit.Limit{N: 600, Window: time.Minute},
+			Store:   "memory",
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// LoadFile: read a TOML file, keys are the field names
+func (c *Config) LoadFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return err
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+	}
+
+	return nil
+}
+
+// ParseNetworks: parse addresses and CIDR ranges, e.g. "10.0.0.1" or "10.0.0.0/8"
+func ParseNetworks(list []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			networks = append(networks, p.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return networks, nil
+}
+
+// Validate: check the values of the configuration
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, a...))
+		}
+	}
+
+	check(c.Server.Listen != "", "Server.Listen is empty")
+	check((c.Server.TLSCert == "") == (c.Server.TLSKey == ""), "Server.TLSCert and Server.TLSKey must be set together")
+	check(c.Server.RequestTimeout >= 0, "Server.RequestTimeout is negative")
+	for name, t := range c.Server.RequestTimeouts {
+		check(t >= 0, "Server.RequestTimeouts.%s is negative", name)
+	}
+	check(c.Server.ShutdownTimeout >= 0, "Server.ShutdownTimeout is negative")
+	check(c.Server.MaxBodySize > 0, "Server.MaxBodySize must be positive")
+	_, err := ParseNetworks(c.Server.TrustedProxies)
+	check(err == nil, "Server.TrustedProxies: %v", err)
+
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		check(c.Database.Addr != "", "Database.Addr is empty")
+		check(c.Database.Name != "", "Database.Name is empty")
+	case "sqlite":
+		check(c.Database.Path != "", "Database.Path is empty")
+	default:
+		check(false, "Database.Driver must be \"mysql\", \"postgres\" or \"sqlite\", not %q", c.Database.Driver)
+	}
+	check(c.Database.MaxOpenConns >= 0, "Database.MaxOpenConns is negative")
+	check(c.Database.MaxIdleConns >= 0, "Database.MaxIdleConns is negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"Database.MaxIdleConns is greater than Database.MaxOpenConns")
+	check(c.Database.ConnMaxLifetime >= 0, "Database.ConnMaxLifetime is negative")
+
+	check(c.Session.Lifetime > 0, "Session.Lifetime must be positive")
+
+	latest := api.Versions[len(api.Versions)-1]
+	for _, name := range c.API.Deprecated {
+		check(api.LookupVersion(name) != nil, "API.Deprecated: unknown version %q", name)
+		check(name != latest.Name, "API.Deprecated: %s is the newest version", name)
+	}
+
+	check(len(c.CORS.Origins) > 0, "CORS.Origins is empty")
+	check(len(c.CORS.Methods) > 0, "CORS.Methods is empty")
+	check(!c.CORS.Credentials || !slices.Contains(c.CORS.Origins, "*"),
+		"CORS.Credentials requires CORS.Origins to list the allowed origins, not \"*\"")
+	check(c.CORS.MaxAge >= 0, "CORS.MaxAge is negative")
+
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "database",
+		"RateLimit.Store must be \"memory\" or \"database\", not %q", c.RateLimit.Store)
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "Log.Level: unknown level %q", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text",
+		"Log.Format must be \"json\" or \"text\", not %q", c.Log.Format)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setValue: parse s into the variable ptr points to
+func setValue(ptr interface{}, s string) error {
+	var err error
+	switch v := ptr.(type) {
+	case encoding.TextUnmarshaler:
+		err = v.UnmarshalText([]byte(s))
+	case *string:
+		*v = s
+	case *int:
+		*v, err = strconv.Atoi(s)
+	case *int64:
+		*v, err = strconv.ParseInt(s, 10, 64)
+	case *bool:
+		*v, err = strconv.ParseBool(s)
+	case *time.Duration:
+		*v, err = time.ParseDuration(s)
+	case *[]string:
+		*v = splitList(s)
+	case *map[string]time.Duration:
+		*v, err = parseDurations(s)
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T", ptr))
+	}
+	return err
+}
+
+// splitList: split a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parseDurations: parse "name=duration,..."
+func parseDurations(s string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, entry := range splitList(s) {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q", entry)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations, nil
+}
+
+// envVars: environment variables and the fields they set
+func (c *Config) envVars() []struct {
+	name string
+	ptr  interface{}
+} {
+	return []struct {
+		name string
+		ptr  interface{}
+	}{
+		{"LISTEN_ADDR", &c.Server.Listen},
+		{"TLS_CERT", &c.Server.TLSCert},
+		{"TLS_KEY", &c.Server.TLSKey},
+		{"REQUEST_TIMEOUT", &c.Server.RequestTimeout},
+		{"REQUEST_TIMEOUTS", &c.Server.RequestTimeouts},
+		{"SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+		{"MAX_BODY_SIZE", &c.Server.MaxBodySize},
+		{"TRUSTED_PROXIES", &c.Server.TrustedProxies},
+
+		{"DB_DRIVER", &c.Database.Driver},
+		{"DB_PATH", &c.Database.Path},
+		{"DBUSER", &c.Database.User},
+		{"DBPASS", &c.Database.Password},
+		{"DB_ADDR", &c.Database.Addr},
+		{"DB_NAME", &c.Database.Name},
+		{"DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime},
+
+		{"SESSION_LIFETIME", &c.Session.Lifetime},
+
+		{"API_DEPRECATED", &c.API.Deprecated},
+
+		// single origin, superseded by CORS_ORIGINS
+		{"RESPONSE_ORIGIN", &c.CORS.Origins},
+		{"CORS_ORIGINS", &c.CORS.Origins},
+		{"CORS_METHODS", &c.CORS.Methods},
+		{"CORS_HEADERS", &c.CORS.Headers},
+		{"CORS_CREDENTIALS", &c.CORS.Credentials},
+		{"CORS_MAX_AGE", &c.CORS.MaxAge},
+
+		{"RATE_LIMIT_LOGIN_IP", &c.RateLimit.LoginIP},
+		{"RATE_LIMIT_LOGIN", &c.RateLimit.Login},
+		{"RATE_LIMIT_TOKEN", &c.RateLimit.Token},
+		{"RATE_LIMIT_STORE", &c.RateLimit.Store},
+
+		{"LOG_FILE", &c.Log.File},
+		{"LOG_LEVEL", &c.Log.Level},
+		{"LOG_FORMAT", &c.Log.Format},
+	}
+}
+
+// LoadEnv: read the environment variables that are set
+func (c *Config) LoadEnv() error {
+	for _, v := range c.envVars() {
+		s, ok := os.LookupEnv(v.name)
+		if !ok || s == "" {
+			continue
+		}
+		if err := setValue(v.ptr, s); err != nil {
+			return fmt.Errorf("%s: %w", v.name, err)
+		}
+	}
+	return nil
+}
+
+// Flags: command-line flags of the configuration
+type Flags struct {
+	fs     *flag.FlagSet
+	path   *string
+	fields map[string]func(c *Config) interface{} // flag name -> field it sets
+}
+
+// RegisterFlags: define the configuration flags in fs, call Load after parsing
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		fs:   fs,
+		path: fs.String("config", os.Getenv("ESTATE_CONFIG"), "configuration file (default $ESTATE_CONFIG)"),
+		fields: map[string]func(c *Config) interface{}{
+			"listen":    func(c *Config) interface{} { return &c.Server.Listen },
+			"tls-cert":  func(c *Config) interface{} { return &c.Server.TLSCert },
+			"tls-key":   func(c *Config) interface{} { return &c.Server.TLSKey },
+			"db-driver": func(c *Config) interface{} { return &c.Database.Driver },
+			"db-path":   func(c *Config) interface{} { return &c.Database.Path },
+			"db-addr":   func(c *Config) interface{} { return &c.Database.Addr },
+			"db-name":   func(c *Config) interface{} { return &c.Database.Name },
+			"db-user":   func(c *Config) interface{} { return &c.Database.User },
+			"log-file":  func(c *Config) interface{} { return &c.Log.File },
+			"log-level": func(c *Config) interface{} { return &c.Log.Level },
+		},
+	}
+
+	fs.String("listen", "", "listen address, host:port")
+	fs.String("tls-cert", "", "TLS certificate file")
+	fs.String("tls-key", "", "TLS private key file")
+	fs.String("db-driver", "", "database driver: mysql, postgres or sqlite")
+	fs.String("db-path", "", "database file of sqlite")
+	fs.String("db-addr", "", "database address, host:port")
+	fs.String("db-name", "", "database name")
+	fs.String("db-user", "", "database user")
+	fs.String("log-file", "", "log file")
+	fs.String("log-level", "", "log level: debug, info, warn or error")
+
+	return f
+}
+
+// Load: build and validate the configuration from defaults, the file,
+// the environment and the flags that were set
+func (f *Flags) Load() (*Config, error) {
+	c := Default()
+
+	if *f.path != "" {
+		if err := c.LoadFile(*f.path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.LoadEnv(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	f.fs.Visit(func(fl *flag.Flag) {
+		field, ok := f.fields[fl.Name]
+		if ok && err == nil {
+			err = setValue(field(c), fl.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, c.Validate()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsConfig: cross-origin access to the API
+type corsConfig struct {
+	Origins     []string // allowed origins, "*" for any
+	Methods     []string // allowed request methods
+	Headers     []string // allowed request headers
+	Credentials bool     // allow cookies and HTTP authentication
+	MaxAge      time.Duration
+}
+
+var cors = corsConfig{
+	Origins: []string{"*"},
+	Methods: []string{http.MethodPost, http.MethodOptions},
+	Headers: []string{"Content-Type", "Accept"},
+	MaxAge:  10 * time.Minute,
+}
+
+func (c *corsConfig) anyOrigin() bool {
+	for _, o := range c.Origins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin: whether requests from origin are allowed
+func (c *corsConfig) allowOrigin(origin string) bool {
+	for _, o := range c.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS: add CORS headers to responses for allowed origins and answer preflight requests
+func withCORS(next http.Handler) http.Handler {
+	methods := strings.Join(cors.Methods, ", ")
+	headers := strings.Join(cors.Headers, ", ")
+	maxAge := strconv.Itoa(int(cors.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// the response depends on the origin unless every origin gets "*"
+		wildcard := cors.anyOrigin() && !cors.Credentials
+		if !wildcard {
+			h.Add("Vary", "Origin")
+		}
+
+		if origin != "" && cors.allowOrigin(origin) {
+			if wildcard {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cors.Credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			h.Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link, "+requestIDHeader)
+
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", methods)
+				h.Set("Access-Control-Allow-Headers", headers)
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+		}
+
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,33 +1,206 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"github.com/go-sql-driver/mysql"
-	"os"
+	"fmt"
+	"strings"
+	"time"
 )
 
-func OpenDB() (*sql.DB, error) {
-	var err error
-	var db *sql.DB
+// Querier: database handle, *sql.DB or *sql.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Config: database connection settings
+type Config struct {
+	Driver          string // "mysql", "postgres" or "sqlite"
+	User            string
+	Password        string
+	Addr            string // host:port of mysql and postgres
+	Name            string // database name of mysql and postgres
+	Path            string // database file of sqlite
+	MaxOpenConns    int    // 0 for no limit
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration // 0 for no limit
+}
 
-	cfg := mysql.Config{
-		User:                 os.Getenv("DBUSER"),
-		Passwd:               os.Getenv("DBPASS"),
-		Net:                  "tcp",
-		Addr:                 "127.0.0.1:3306",
-		DBName:               "estate",
-		AllowNativePasswords: true,
+// dialect: differences between SQL databases
+type dialect struct {
+	name        string
+	isDuplicate func(err error) bool      // unique constraint violation
+	rebind      func(query string) string // rewrite ? placeholders, nil to keep them
+	quote       func(ident string) string // quote a reserved word used as a name
+	returningID bool                      // INSERT ... RETURNING id instead of LastInsertId
+	like        string                    // case-insensitive LIKE with a ? pattern, \ escapes
+}
+
+// querier: q with the placeholders of queries rewritten for d
+func (d *dialect) querier(q Querier) Querier {
+	if d.rebind == nil {
+		return q
 	}
+	return rebound{q: q, rebind: d.rebind}
+}
+
+// rebound: Querier rewriting placeholders
+type rebound struct {
+	q      Querier
+	rebind func(query string) string
+}
+
+func (r rebound) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.q.ExecContext(ctx, r.rebind(query), args...)
+}
+
+func (r rebound) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.q.QueryContext(ctx, r.rebind(query), args...)
+}
+
+func (r rebound) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.q.QueryRowContext(ctx, r.rebind(query), args...)
+}
 
-	db, err = sql.Open("mysql", cfg.FormatDSN())
+// SQLStore: Store in an SQL database
+type SQLStore struct {
+	db   *sql.DB
+	q    Querier // db or the transaction in progress
+	d    *dialect
+	inTx bool
+}
+
+func newSQLStore(db *sql.DB, d *dialect) *SQLStore {
+	return &SQLStore{db: db, q: d.querier(db), d: d}
+}
+
+// Open: connect to the database of the configured driver
+func Open(c Config) (Store, error) {
+	var s *SQLStore
+	var err error
+	switch c.Driver {
+	case "", "mysql":
+		s, err = openMySQL(c)
+	case "postgres":
+		s, err = openPostgres(c)
+	case "sqlite":
+		s, err = openSQLite(c)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", c.Driver)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.Ping()
+	s.db.SetMaxOpenConns(c.MaxOpenConns)
+	s.db.SetMaxIdleConns(c.MaxIdleConns)
+	s.db.SetConnMaxLifetime(c.ConnMaxLifetime)
+
+	err = s.db.Ping()
 	if err != nil {
+		s.db.Close()
 		return nil, err
 	}
 
-	return db, nil
+	return s, nil
+}
+
+// DB: connection pool of the store
+func (s *SQLStore) DB() *sql.DB {
+	return s.db
+}
+
+// Driver: name of the SQL dialect, "mysql", "postgres" or "sqlite"
+func (s *SQLStore) Driver() string {
+	return s.d.name
+}
+
+// duplicate: sentinel if err is a unique constraint violation, err otherwise
+func (s *SQLStore) duplicate(err error, sentinel error) error {
+	if s.d.isDuplicate(err) {
+		return sentinel
+	}
+	return err
+}
+
+// insert: run an INSERT into a table with an id column, return the new id
+func (s *SQLStore) insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if s.d.returningID {
+		var id int64
+		err := s.q.QueryRowContext(ctx, strings.TrimSuffix(query, ";")+" RETURNING id;", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := s.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// WithTx: run fn in a transaction of db, committed if fn returns nil
+// and rolled back otherwise
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.inTx {
+		// already in a transaction
+		return fn(s)
+	}
+
+	return WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&SQLStore{db: s.db, q: s.d.querier(tx), d: s.d, inTx: true})
+	})
+}
+
+func (s *SQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// schemaTables: tables the backend works with
+var schemaTables = []string{"users", "grps", "user_group_rel", "objects", "tasks", "sessions"}
+
+// CheckSchema: check that all migrations are applied and all tables
+// of the schema exist
+func (s *SQLStore) CheckSchema(ctx context.Context) error {
+	migrations, err := loadMigrations(s.d.name)
+	if err != nil {
+		return err
+	}
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].Version; version < latest {
+		return fmt.Errorf("%w: version %d, need %d", ErrSchemaOld, version, latest)
+	}
+
+	for _, table := range schemaTables {
+		rows, err := s.q.QueryContext(ctx, "SELECT * FROM "+table+" LIMIT 0;")
+		if err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+		rows.Close()
+	}
+
+	return nil
 }
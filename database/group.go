@@ -1,11 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-
-	"github.com/go-sql-driver/mysql"
 )
 
 var ErrGroupExists = errors.New("group already exists")
@@ -18,8 +17,8 @@ type Group struct {
 	Name string
 }
 
-func GetGroup(db *sql.DB, gid int64) (*Group, error) {
-	row := db.QueryRow(
+func (s *SQLStore) GetGroup(ctx context.Context, gid int64) (*Group, error) {
+	row := s.q.QueryRowContext(ctx,
 		"SELECT * FROM grps WHERE id=?;",
 		gid,
 	)
@@ -38,8 +37,8 @@ func GetGroup(db *sql.DB, gid int64) (*Group, error) {
 	return &group, nil
 }
 
-func FindGroup(db *sql.DB, name string) (*Group, error) {
-	row := db.QueryRow(
+func (s *SQLStore) FindGroup(ctx context.Context, name string) (*Group, error) {
+	row := s.q.QueryRowContext(ctx,
 		"SELECT * FROM grps WHERE name=?;",
 		name,
 	)
@@ -58,33 +57,30 @@ func FindGroup(db *sql.DB, name string) (*Group, error) {
 	return &group, nil
 }
 
-func CreateGroup(db *sql.DB, name string) (*Group, error) {
-	result, err := db.Exec(
+func (s *SQLStore) CreateGroup(ctx context.Context, name string) (*Group, error) {
+	id, err := s.insert(ctx,
 		"INSERT INTO grps (name) VALUES (?);",
 		name,
 	)
 	if err != nil {
-		switch e := err.(type) {
-		case *mysql.MySQLError:
-			if e.Number == 1062 {
-				return nil, ErrGroupExists
-			}
-		}
-		return nil, err
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return nil, err
+		return nil, s.duplicate(err, ErrGroupExists)
 	}
 
 	return &Group{Id: id, Name: name}, nil
 }
 
-func RemoveGroup(db *sql.DB, gid int64) error {
+// RemoveGroup: remove the group and its members in one transaction,
+// the members are kept if the group cannot be removed
+func (s *SQLStore) RemoveGroup(ctx context.Context, gid int64) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		return tx.(*SQLStore).removeGroup(ctx, gid)
+	})
+}
+
+func (s *SQLStore) removeGroup(ctx context.Context, gid int64) error {
 	// remove all users from the group
 
-	result, err := db.Exec(
+	result, err := s.q.ExecContext(ctx,
 		"DELETE FROM user_group_rel WHERE gid=?;",
 		gid,
 	)
@@ -94,7 +90,7 @@ func RemoveGroup(db *sql.DB, gid int64) error {
 
 	// remove group itself
 
-	result, err = db.Exec(
+	result, err = s.q.ExecContext(ctx,
 		"DELETE FROM grps WHERE id=?;",
 		gid,
 	)
@@ -113,26 +109,20 @@ func RemoveGroup(db *sql.DB, gid int64) error {
 	return nil
 }
 
-func GroupAddUser(db *sql.DB, uid int64, gid int64) error {
-	_, err := db.Exec(
+func (s *SQLStore) GroupAddUser(ctx context.Context, uid int64, gid int64) error {
+	_, err := s.q.ExecContext(ctx,
 		"INSERT INTO user_group_rel (uid, gid) VALUES (?,?);",
 		uid, gid,
 	)
 	if err != nil {
-		switch e := err.(type) {
-		case *mysql.MySQLError:
-			if e.Number == 1062 {
-				return ErrAlreadyInGroup
-			}
-		}
-		return err
+		return s.duplicate(err, ErrAlreadyInGroup)
 	}
 
 	return nil
 }
 
-func GroupRemoveUser(db *sql.DB, uid int64, gid int64) error {
-	result, err := db.Exec(
+func (s *SQLStore) GroupRemoveUser(ctx context.Context, uid int64, gid int64) error {
+	result, err := s.q.ExecContext(ctx,
 		"DELETE FROM user_group_rel WHERE uid=? AND gid=?;",
 		uid, gid,
 	)
@@ -152,8 +142,8 @@ func GroupRemoveUser(db *sql.DB, uid int64, gid int64) error {
 	return nil
 }
 
-func IsUserInGroup(db *sql.DB, uid int64, gid int64) bool {
-	row := db.QueryRow(
+func (s *SQLStore) IsUserInGroup(ctx context.Context, uid int64, gid int64) bool {
+	row := s.q.QueryRowContext(ctx,
 		"SELECT * FROM user_group_rel WHERE uid=? OR gid=?",
 		uid, gid,
 	)
@@ -168,15 +158,16 @@ const (
 	UserListGroups ElementsToList = 1
 )
 
-func ListGroupsOrUsers(db *sql.DB, toList ElementsToList, id int64) ([]int64, error) {
+func (s *SQLStore) ListGroupsOrUsers(ctx context.Context, toList ElementsToList, id int64) ([]int64, error) {
 	id1, id2 := [2]string{"uid", "gid"}[toList], [2]string{"gid", "uid"}[toList]
-	rows, err := db.Query(
+	rows, err := s.q.QueryContext(ctx,
 		fmt.Sprintf("SELECT %s FROM user_group_rel WHERE %s=?;", id1, id2),
 		id,
 	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	var uids []int64
 	for rows.Next() {
@@ -0,0 +1,615 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore: Store keeping everything in memory, for development and
+// tests; the data is lost when the process exits
+type MemoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	inTx bool // mu is held by the transaction
+}
+
+// memoryData: tables of a MemoryStore
+type memoryData struct {
+	users    map[int64]UserInfo
+	groups   map[int64]Group
+	members  map[[2]int64]bool // {uid, gid}
+	sessions map[string]Session
+	objects  map[int64]StructInfo
+	tasks    map[int64]Task
+
+	// last ids by table
+	lastUser, lastGroup, lastSession, lastObject, lastTask int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			users:    make(map[int64]UserInfo),
+			groups:   make(map[int64]Group),
+			members:  make(map[[2]int64]bool),
+			sessions: make(map[string]Session),
+			objects:  make(map[int64]StructInfo),
+			tasks:    make(map[int64]Task),
+		},
+	}
+}
+
+// clone: copy of the tables, rows are values and are not shared
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		users:    make(map[int64]UserInfo, len(d.users)),
+		groups:   make(map[int64]Group, len(d.groups)),
+		members:  make(map[[2]int64]bool, len(d.members)),
+		sessions: make(map[string]Session, len(d.sessions)),
+		objects:  make(map[int64]StructInfo, len(d.objects)),
+		tasks:    make(map[int64]Task, len(d.tasks)),
+	}
+	c.lastUser, c.lastGroup, c.lastSession, c.lastObject, c.lastTask =
+		d.lastUser, d.lastGroup, d.lastSession, d.lastObject, d.lastTask
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.groups {
+		c.groups[k] = v
+	}
+	for k, v := range d.members {
+		c.members[k] = v
+	}
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range d.objects {
+		c.objects[k] = v
+	}
+	for k, v := range d.tasks {
+		c.tasks[k] = v
+	}
+	return c
+}
+
+// nextId: id of a new row of the table with the last id
+func nextId(last *int64) int64 {
+	*last++
+	return *last
+}
+
+// lock: take the lock unless in a transaction, returns the unlock function
+func (m *MemoryStore) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+/*
+ * Users
+ */
+
+func (m *MemoryStore) RegisterUser(ctx context.Context, u *UserInfo) (int64, error) {
+	defer m.lock()()
+
+	for _, user := range m.data.users {
+		if user.Login == u.Login {
+			return 0, ErrUserExists
+		}
+	}
+
+	user := *u
+	user.Id = nextId(&m.data.lastUser)
+	m.data.users[user.Id] = user
+	return user.Id, nil
+}
+
+func (m *MemoryStore) GetUserInfo(ctx context.Context, id int64) (*UserInfo, error) {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return nil, ErrNoUser
+	}
+	return &user, nil
+}
+
+func (m *MemoryStore) FindUserInfo(ctx context.Context, login string) (*UserInfo, error) {
+	defer m.lock()()
+
+	for _, user := range m.data.users {
+		if user.Login == login {
+			return &user, nil
+		}
+	}
+	return nil, ErrNoUser
+}
+
+// updateUser: apply change to the user, a missing user is not an error
+// as with the SQL stores
+func (m *MemoryStore) updateUser(id int64, change func(u *UserInfo)) {
+	user, ok := m.data.users[id]
+	if !ok {
+		return
+	}
+	change(&user)
+	m.data.users[id] = user
+}
+
+func (m *MemoryStore) UserChangeLogin(ctx context.Context, id int64, newLogin string) error {
+	defer m.lock()()
+
+	for _, user := range m.data.users {
+		if user.Login == newLogin && user.Id != id {
+			return ErrUserExists
+		}
+	}
+
+	m.updateUser(id, func(u *UserInfo) { u.Login = newLogin })
+	return nil
+}
+
+func (m *MemoryStore) UserChangePasswordHash(ctx context.Context, id int64, passHash []byte) error {
+	defer m.lock()()
+
+	m.updateUser(id, func(u *UserInfo) { u.PassHash = passHash })
+	return nil
+}
+
+func (m *MemoryStore) UserChangeName(ctx context.Context, id int64, nameType int, newName string) error {
+	if nameType < 0 || nameType > 2 {
+		return fmt.Errorf("invalid name type")
+	}
+
+	defer m.lock()()
+
+	m.updateUser(id, func(u *UserInfo) {
+		switch nameType {
+		case 0:
+			u.FirstName = newName
+		case 1:
+			u.LastName = newName
+		case 2:
+			u.Patronymic = newName
+		}
+	})
+	return nil
+}
+
+func (m *MemoryStore) UserSetManagesGroups(ctx context.Context, id int64, managesGroups bool) error {
+	defer m.lock()()
+
+	m.updateUser(id, func(u *UserInfo) { u.ManagesGroups = managesGroups })
+	return nil
+}
+
+/*
+ * Groups
+ */
+
+func (m *MemoryStore) GetGroup(ctx context.Context, gid int64) (*Group, error) {
+	defer m.lock()()
+
+	group, ok := m.data.groups[gid]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	return &group, nil
+}
+
+func (m *MemoryStore) FindGroup(ctx context.Context, name string) (*Group, error) {
+	defer m.lock()()
+
+	for _, group := range m.data.groups {
+		if group.Name == name {
+			return &group, nil
+		}
+	}
+	return nil, ErrNoGroup
+}
+
+func (m *MemoryStore) CreateGroup(ctx context.Context, name string) (*Group, error) {
+	defer m.lock()()
+
+	for _, group := range m.data.groups {
+		if group.Name == name {
+			return nil, ErrGroupExists
+		}
+	}
+
+	group := Group{Id: nextId(&m.data.lastGroup), Name: name}
+	m.data.groups[group.Id] = group
+	return &group, nil
+}
+
+func (m *MemoryStore) RemoveGroup(ctx context.Context, gid int64) error {
+	defer m.lock()()
+
+	if _, ok := m.data.groups[gid]; !ok {
+		return ErrNoGroup
+	}
+
+	// like the foreign keys of the SQL stores
+	for _, strct := range m.data.objects {
+		if strct.Gid == gid {
+			return fmt.Errorf("group %d is used by object %d", gid, strct.Id)
+		}
+	}
+	for _, task := range m.data.tasks {
+		if task.Gid == gid {
+			return fmt.Errorf("group %d is used by task %d", gid, task.Id)
+		}
+	}
+
+	for key := range m.data.members {
+		if key[1] == gid {
+			delete(m.data.members, key)
+		}
+	}
+	delete(m.data.groups, gid)
+	return nil
+}
+
+func (m *MemoryStore) GroupAddUser(ctx context.Context, uid int64, gid int64) error {
+	defer m.lock()()
+
+	key := [2]int64{uid, gid}
+	if m.data.members[key] {
+		return ErrAlreadyInGroup
+	}
+	m.data.members[key] = true
+	return nil
+}
+
+func (m *MemoryStore) GroupRemoveUser(ctx context.Context, uid int64, gid int64) error {
+	defer m.lock()()
+
+	key := [2]int64{uid, gid}
+	if !m.data.members[key] {
+		return ErrNotInGroup
+	}
+	delete(m.data.members, key)
+	return nil
+}
+
+func (m *MemoryStore) ListGroupsOrUsers(ctx context.Context, toList ElementsToList, id int64) ([]int64, error) {
+	defer m.lock()()
+
+	var ids []int64
+	for key := range m.data.members {
+		switch {
+		case toList == GroupListUsers && key[1] == id:
+			ids = append(ids, key[0])
+		case toList == UserListGroups && key[0] == id:
+			ids = append(ids, key[1])
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+/*
+ * Sessions
+ */
+
+func (m *MemoryStore) AddSession(ctx context.Context, session *Session) error {
+	defer m.lock()()
+
+	if _, ok := m.data.sessions[string(session.Token)]; ok {
+		return fmt.Errorf("duplicate session token")
+	}
+
+	session.Id = nextId(&m.data.lastSession)
+	m.data.sessions[string(session.Token)] = *session
+	return nil
+}
+
+func (m *MemoryStore) CloseSession(ctx context.Context, token []byte) error {
+	defer m.lock()()
+
+	if _, ok := m.data.sessions[string(token)]; !ok {
+		return ErrNotLoggedIn
+	}
+	delete(m.data.sessions, string(token))
+	return nil
+}
+
+func (m *MemoryStore) VerifySession(ctx context.Context, token []byte) (*Session, error) {
+	defer m.lock()()
+
+	session, ok := m.data.sessions[string(token)]
+	if !ok {
+		return nil, ErrNotLoggedIn
+	}
+	if session.expired() {
+		delete(m.data.sessions, string(token))
+		return nil, ErrNotLoggedIn
+	}
+	return &session, nil
+}
+
+/*
+ * Objects
+ */
+
+func (m *MemoryStore) AddStruct(ctx context.Context, strct *StructInfo) error {
+	defer m.lock()()
+
+	strct.Id = nextId(&m.data.lastObject)
+	m.data.objects[strct.Id] = *strct
+	return nil
+}
+
+func (m *MemoryStore) GetStructInfo(ctx context.Context, id int64) (*StructInfo, error) {
+	defer m.lock()()
+
+	strct, ok := m.data.objects[id]
+	if !ok {
+		return nil, ErrNoStruct
+	}
+	return &strct, nil
+}
+
+func (m *MemoryStore) FindStructures(ctx context.Context, filter ArgsFStructFind) ([]StructInfo, error) {
+	defer m.lock()()
+
+	match := func(s StructInfo) bool {
+		switch {
+		case filter.Name != "" && s.Name != filter.Name,
+			filter.Description != "" && s.Description != filter.Description,
+			filter.District != "" && s.District != filter.District,
+			filter.Region != "" && s.Region != filter.Region,
+			filter.Address != "" && s.Address != filter.Address,
+			filter.Type != "" && s.Type != filter.Type,
+			filter.State != "" && s.State != filter.State,
+			filter.Gid != nil && s.Gid != *filter.Gid:
+			return false
+		}
+
+		// same bounds as the SQL stores: inclusive if both are set
+		switch {
+		case filter.AreaFrom != nil && filter.AreaTo != nil:
+			return s.Area >= *filter.AreaFrom && s.Area <= *filter.AreaTo
+		case filter.AreaTo != nil:
+			return s.Area < *filter.AreaTo
+		case filter.AreaFrom != nil:
+			return s.Area > *filter.AreaFrom
+		}
+		return true
+	}
+
+	structures := make([]StructInfo, 0)
+	for _, s := range m.data.objects {
+		if match(s) {
+			structures = append(structures, s)
+		}
+	}
+	sort.Slice(structures, func(i, j int) bool { return structures[i].Id < structures[j].Id })
+
+	return page(structures, filter.Limit, filter.Offset), nil
+}
+
+func (m *MemoryStore) DeleteStruct(ctx context.Context, id int64) error {
+	defer m.lock()()
+
+	if _, ok := m.data.objects[id]; !ok {
+		return ErrNoStruct
+	}
+	delete(m.data.objects, id)
+	return nil
+}
+
+// updateStruct: apply change to the object, a missing object is not an error
+// as with the SQL stores
+func (m *MemoryStore) updateStruct(id int64, change func(s *StructInfo)) error {
+	defer m.lock()()
+
+	strct, ok := m.data.objects[id]
+	if !ok {
+		return nil
+	}
+	change(&strct)
+	m.data.objects[id] = strct
+	return nil
+}
+
+func (m *MemoryStore) StructChangeName(ctx context.Context, id int64, newName string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Name = newName })
+}
+
+func (m *MemoryStore) StructChangeDescription(ctx context.Context, id int64, newDescription string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Description = newDescription })
+}
+
+func (m *MemoryStore) StructChangeDistrict(ctx context.Context, id int64, newDistrict string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.District = newDistrict })
+}
+
+func (m *MemoryStore) StructChangeRegion(ctx context.Context, id int64, newRegion string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Region = newRegion })
+}
+
+func (m *MemoryStore) StructChangeAddress(ctx context.Context, id int64, newAddress string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Address = newAddress })
+}
+
+func (m *MemoryStore) StructChangeType(ctx context.Context, id int64, newType string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Type = newType })
+}
+
+func (m *MemoryStore) StructChangeState(ctx context.Context, id int64, newState string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.State = newState })
+}
+
+func (m *MemoryStore) StructChangeArea(ctx context.Context, id int64, newArea int32) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Area = newArea })
+}
+
+func (m *MemoryStore) StructChangeOwner(ctx context.Context, id int64, newOwner string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Owner = newOwner })
+}
+
+func (m *MemoryStore) StructChangeActualUser(ctx context.Context, id int64, newActualUser string) error {
+	return m.updateStruct(id, func(s *StructInfo) { s.Actual_user = newActualUser })
+}
+
+func (m *MemoryStore) StructChangePermissions(ctx context.Context, id int64, newPermission int8) error {
+	if newPermission > 63 {
+		return ErrBigPermission
+	}
+	return m.updateStruct(id, func(s *StructInfo) { s.Permissions = newPermission })
+}
+
+/*
+ * Tasks
+ */
+
+func (m *MemoryStore) CreateTask(ctx context.Context, task *Task) (int64, error) {
+	defer m.lock()()
+
+	t := *task
+	t.Id = nextId(&m.data.lastTask)
+	m.data.tasks[t.Id] = t
+	return t.Id, nil
+}
+
+func (m *MemoryStore) RemoveTask(ctx context.Context, id int64) error {
+	defer m.lock()()
+
+	if _, ok := m.data.tasks[id]; !ok {
+		return ErrNoTask
+	}
+	delete(m.data.tasks, id)
+	return nil
+}
+
+func (m *MemoryStore) GetTask(ctx context.Context, id int64) (*Task, error) {
+	defer m.lock()()
+
+	task, ok := m.data.tasks[id]
+	if !ok {
+		return nil, ErrNoTask
+	}
+	return &task, nil
+}
+
+func (m *MemoryStore) FilterTasks(ctx context.Context, filter *TaskFilter) ([]*Task, error) {
+	defer m.lock()()
+
+	match := func(t Task) bool {
+		switch {
+		case filter.Name != nil && !like(t.Name, *filter.Name),
+			filter.Description != nil && !like(t.Description, *filter.Description),
+			filter.DeadlineFrom != nil && t.Deadline < *filter.DeadlineFrom,
+			filter.DeadlineTo != nil && t.Deadline > *filter.DeadlineTo,
+			filter.Status != nil && !like(t.Status, *filter.Status),
+			filter.Object != nil && t.Object != *filter.Object,
+			filter.Maintainer != nil && t.Maintainer != *filter.Maintainer,
+			filter.Gid != nil && t.Gid != *filter.Gid:
+			return false
+		}
+		return true
+	}
+
+	tasks := make([]*Task, 0)
+	for _, t := range m.data.tasks {
+		if match(t) {
+			t := t
+			tasks = append(tasks, &t)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Id < tasks[j].Id })
+
+	return page(tasks, filter.Limit, filter.Offset), nil
+}
+
+// like: s matches the SQL LIKE pattern, case-insensitive
+func like(s string, pattern string) bool {
+	s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+	sr, pr := []rune(s), []rune(pattern)
+
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		for ; j < len(pr); j++ {
+			switch pr[j] {
+			case '%':
+				for k := i; k <= len(sr); k++ {
+					if match(k, j+1) {
+						return true
+					}
+				}
+				return false
+			case '_':
+				if i >= len(sr) {
+					return false
+				}
+			case '\\':
+				if j+1 < len(pr) {
+					j++
+				}
+				fallthrough
+			default:
+				if i >= len(sr) || sr[i] != pr[j] {
+					return false
+				}
+			}
+			i++
+		}
+		return i == len(sr)
+	}
+	return match(0, 0)
+}
+
+// page: part of rows selected by LIMIT and OFFSET
+func page[T any](rows []T, limit int16, offset int16) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if int(offset) >= len(rows) {
+		return rows[:0]
+	}
+	rows = rows[offset:]
+	if limit >= 0 && int(limit) < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+/*
+ * Maintenance
+ */
+
+func (m *MemoryStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+
+	// transactions run one at a time, on a snapshot restored on failure
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := m.data.clone()
+	err := fn(&MemoryStore{mu: m.mu, data: m.data, inTx: true})
+	if err != nil {
+		*m.data = *snapshot
+	}
+	return err
+}
+
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) CheckSchema(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles: migrations/<driver>/<version>_<name>.up.sql and .down.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var ErrSchemaOld = errors.New("database schema is out of date, run estatectl migrate up")
+var ErrNoMigration = errors.New("no migration to revert")
+
+// Migration: versioned change of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // SQL applying the change
+	Down    string // SQL reverting it
+}
+
+// MigrationState: migration and whether it is applied
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time // zero if not applied
+}
+
+// loadMigrations: migrations of the dialect by version
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		base, up := strings.CutSuffix(name, ".up.sql")
+		if !up {
+			var ok bool
+			base, ok = strings.CutSuffix(name, ".down.sql")
+			if !ok {
+				return nil, fmt.Errorf("migration %s: not .up.sql or .down.sql", name)
+			}
+		}
+
+		num, title, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name is not VERSION_NAME", name)
+		}
+
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if up {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: up or down is missing", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// splitStatements: statements of an SQL script, without comments
+func splitStatements(script string) []string {
+	var statements []string
+	var b strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			statements = append(statements, s)
+		}
+		b.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += 2 + end + 1
+			}
+			b.WriteByte(' ')
+		case c == '\'':
+			// string literal, '' is an escaped quote
+			j := i + 1
+			for j < len(script) {
+				if script[j] == '\'' {
+					if j+1 < len(script) && script[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			b.WriteString(script[i:min(j+1, len(script))])
+			i = j
+		case c == ';':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// tableExists: the table can be queried
+func (s *SQLStore) tableExists(ctx context.Context, table string) bool {
+	rows, err := s.q.QueryContext(ctx, "SELECT * FROM "+table+" LIMIT 0;")
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// initMigrations: create the schema_migrations table; a database created
+// from db.sql before migrations existed is recorded at version 1, the tables
+// of db.sql, and gets the later tables from the following migrations
+func (s *SQLStore) initMigrations(ctx context.Context) error {
+	if s.tableExists(ctx, "schema_migrations") {
+		return nil
+	}
+
+	_, err := s.q.ExecContext(ctx,
+		"CREATE TABLE schema_migrations (version integer primary key, applied_at bigint not null);")
+	if err != nil {
+		return err
+	}
+
+	if s.tableExists(ctx, "users") {
+		_, err = s.q.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, applied_at) VALUES (?,?);",
+			1, time.Now().Unix(),
+		)
+	}
+	return err
+}
+
+// SchemaVersion: version of the last applied migration, 0 for none
+func (s *SQLStore) SchemaVersion(ctx context.Context) (int, error) {
+	if !s.tableExists(ctx, "schema_migrations") {
+		return 0, nil
+	}
+
+	var version int
+	err := s.q.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations;").Scan(&version)
+	return version, err
+}
+
+// Migrations: all migrations of the database and whether they are applied
+func (s *SQLStore) Migrations(ctx context.Context) ([]MigrationState, error) {
+	migrations, err := loadMigrations(s.d.name)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	if s.tableExists(ctx, "schema_migrations") {
+		rows, err := s.q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations;")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int
+			var at int64
+			if err := rows.Scan(&version, &at); err != nil {
+				return nil, err
+			}
+			applied[version] = time.Unix(at, 0)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		at, ok := applied[m.Version]
+		states[i] = MigrationState{Migration: m, Applied: ok, AppliedAt: at}
+	}
+	return states, nil
+}
+
+// run: execute the statements of script, each migration step in a transaction
+// (MySQL commits DDL statements immediately)
+func (s *SQLStore) run(ctx context.Context, script string, record string, args ...interface{}) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		q := tx.(*SQLStore).q
+		for _, statement := range splitStatements(script) {
+			if _, err := q.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		_, err := q.ExecContext(ctx, record, args...)
+		return err
+	})
+}
+
+// MigrateUp: apply the migrations that are not applied yet, in order;
+// returns the applied ones
+func (s *SQLStore) MigrateUp(ctx context.Context) ([]Migration, error) {
+	err := s.initMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := s.Migrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range states {
+		if m.Applied {
+			continue
+		}
+
+		err = s.run(ctx, m.Up,
+			"INSERT INTO schema_migrations (version, applied_at) VALUES (?,?);",
+			m.Version, time.Now().Unix(),
+		)
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m.Migration)
+	}
+
+	return done, nil
+}
+
+// MigrateDown: revert the last applied migration
+func (s *SQLStore) MigrateDown(ctx context.Context) (*Migration, error) {
+	states, err := s.Migrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(states) - 1; i >= 0; i-- {
+		m := states[i]
+		if !m.Applied {
+			continue
+		}
+
+		err = s.run(ctx, m.Down, "DELETE FROM schema_migrations WHERE version=?;", m.Version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		return &m.Migration, nil
+	}
+
+	return nil, ErrNoMigration
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"only comments", "-- nothing here;\n/* nor; here */ ;\n", nil},
+		{"statements", "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n", []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"}},
+		{"trailing statement without ;", "DROP TABLE a;\nDROP TABLE b", []string{"DROP TABLE a", "DROP TABLE b"}},
+		{"; in a string", "INSERT INTO a VALUES ('x;y');", []string{"INSERT INTO a VALUES ('x;y')"}},
+		{"escaped quote", "INSERT INTO a VALUES ('it''s; fine');", []string{"INSERT INTO a VALUES ('it''s; fine')"}},
+		{"comment start in a string", "INSERT INTO a VALUES ('--x', '/*y');", []string{"INSERT INTO a VALUES ('--x', '/*y')"}},
+		{"; in a line comment", "CREATE TABLE a (\n\tid int -- the id; unique\n);", []string{"CREATE TABLE a (\n\tid int \n)"}},
+		{"; in a block comment", "SELECT /* 1; */ 2;", []string{"SELECT   2"}},
+		{"unterminated string", "SELECT 'x;", []string{"SELECT 'x;"}},
+		{"unterminated comment", "SELECT 1; /* x;", []string{"SELECT 1"}},
+	}
+	for _, tt := range tests {
+		if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// openEmptyStore: sqlite store without migrations applied
+func openEmptyStore(t *testing.T) *SQLStore {
+	t.Helper()
+	db, err := Open(Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "estate.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.(*SQLStore)
+}
+
+// migrateUp: run MigrateUp, versions of the applied migrations
+func migrateUp(t *testing.T, s *SQLStore) []int {
+	t.Helper()
+	migrations, err := s.MigrateUp(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	s := openEmptyStore(t)
+
+	if got := migrateUp(t, s); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("MigrateUp: applied %v, want [1 2]", got)
+	}
+	if got := migrateUp(t, s); got != nil {
+		t.Errorf("MigrateUp of an up-to-date database: applied %v", got)
+	}
+
+	m, err := s.MigrateDown(ctx)
+	if err != nil || m.Version != 2 {
+		t.Fatalf("MigrateDown: got %+v, %v", m, err)
+	}
+	if s.tableExists(ctx, "rate_limits") || !s.tableExists(ctx, "users") {
+		t.Error("MigrateDown of 0002: rate_limits should be dropped, users kept")
+	}
+	if got := migrateUp(t, s); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("MigrateUp after MigrateDown: applied %v, want [2]", got)
+	}
+
+	for _, want := range []int{2, 1} {
+		if m, err = s.MigrateDown(ctx); err != nil || m.Version != want {
+			t.Fatalf("MigrateDown: got %+v, %v, want version %d", m, err, want)
+		}
+	}
+	_, err = s.MigrateDown(ctx)
+	expectErr(t, "MigrateDown without migrations", err, ErrNoMigration)
+}
+
+// TestMigrateExisting: a database created from db.sql before migrations
+// existed, which has the tables of 0001, only gets 0002
+func TestMigrateExisting(t *testing.T) {
+	ctx := context.Background()
+	s := openEmptyStore(t)
+
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range splitStatements(migrations[0].Up) {
+		if _, err := s.q.ExecContext(ctx, statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uid := addTestUser(t, s, "ivanov")
+
+	if got := migrateUp(t, s); !reflect.DeepEqual(got, []int{2}) {
+		t.Fatalf("MigrateUp: applied %v, want [2]", got)
+	}
+	version, err := s.SchemaVersion(ctx)
+	if err != nil || version != 2 {
+		t.Errorf("SchemaVersion: got %d, %v, want 2", version, err)
+	}
+	if !s.tableExists(ctx, "rate_limits") {
+		t.Error("rate_limits is not created")
+	}
+	if u, err := s.GetUserInfo(ctx, uid); err != nil || u.Login != "ivanov" {
+		t.Errorf("user of the existing database: got %+v, %v", u, err)
+	}
+}
@@ -0,0 +1,83 @@
+-- tables of the postgres backend, same as mysql/0001_init.up.sql
+
+create table users
+(
+    id             serial primary key,
+    login          varchar(256) not null unique,
+    pass_hash      bytea        not null,
+    first_name     varchar(256) not null,
+    last_name      varchar(256) not null,
+    patronymic     varchar(256) not null,
+    manages_groups boolean      not null
+);
+
+create table grps
+(
+    id   serial primary key,
+    name varchar(256) not null unique
+);
+
+create table user_group_rel
+(
+    uid integer not null references users (id),
+    gid integer not null references grps (id),
+
+    unique (uid, gid)
+);
+
+create table objects
+(
+    id          serial primary key,
+    name        text         not null,
+    description text         not null,
+    district    text         not null,
+    region      text         not null,
+    address     text         not null,
+    type        varchar(256) not null,
+    state       text         not null,
+    area        integer      not null,
+    owner       text         not null,
+    actual_user text         not null,
+
+    gid         integer  not null references grps (id),
+    permissions smallint not null -- see mysql/0001_init.up.sql
+);
+
+create table tasks
+(
+    id          serial primary key,
+    name        text         not null,
+    description text         not null,
+    deadline    integer      null,
+    status      varchar(256) not null,
+
+    object      integer not null references objects (id),
+
+    maintainer  integer  not null references users (id),
+    gid         integer  not null references grps (id),
+    permissions smallint not null
+);
+
+create table tags
+(
+    id     serial primary key,
+    name   varchar(256) not null,
+    task   integer      not null references tasks (id),
+    author integer      not null references users (id)
+);
+
+create table attachments
+(
+    id     serial primary key,
+    title  text    not null,
+    object integer not null references objects (id),
+    author integer not null references users (id)
+);
+
+create table sessions
+(
+    id          serial primary key,
+    token       varchar(32) not null unique,
+    expiry_date integer     not null,
+    "user"      integer     not null references users (id)
+);
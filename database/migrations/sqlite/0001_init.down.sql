@@ -0,0 +1,8 @@
+drop table sessions;
+drop table attachments;
+drop table tags;
+drop table tasks;
+drop table objects;
+drop table user_group_rel;
+drop table grps;
+drop table users;
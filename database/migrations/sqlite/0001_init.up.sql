@@ -0,0 +1,83 @@
+-- tables of the sqlite backend, same as mysql/0001_init.up.sql
+
+create table users
+(
+    id             integer primary key autoincrement,
+    login          text    not null unique,
+    pass_hash      blob    not null,
+    first_name     text    not null,
+    last_name      text    not null,
+    patronymic     text    not null,
+    manages_groups boolean not null
+);
+
+create table grps
+(
+    id   integer primary key autoincrement,
+    name text not null unique
+);
+
+create table user_group_rel
+(
+    uid integer not null references users (id),
+    gid integer not null references grps (id),
+
+    unique (uid, gid)
+);
+
+create table objects
+(
+    id          integer primary key autoincrement,
+    name        text    not null,
+    description text    not null,
+    district    text    not null,
+    region      text    not null,
+    address     text    not null,
+    type        text    not null,
+    state       text    not null,
+    area        integer not null,
+    owner       text    not null,
+    actual_user text    not null,
+
+    gid         integer not null references grps (id),
+    permissions integer not null -- see mysql/0001_init.up.sql
+);
+
+create table tasks
+(
+    id          integer primary key autoincrement,
+    name        text    not null,
+    description text    not null,
+    deadline    integer null,
+    status      text    not null,
+
+    object      integer not null references objects (id),
+
+    maintainer  integer not null references users (id),
+    gid         integer not null references grps (id),
+    permissions integer not null
+);
+
+create table tags
+(
+    id     integer primary key autoincrement,
+    name   text    not null,
+    task   integer not null references tasks (id),
+    author integer not null references users (id)
+);
+
+create table attachments
+(
+    id     integer primary key autoincrement,
+    title  text    not null,
+    object integer not null references objects (id),
+    author integer not null references users (id)
+);
+
+create table sessions
+(
+    id          integer primary key autoincrement,
+    token       text    not null unique,
+    expiry_date integer not null,
+    user        integer not null references users (id)
+);
@@ -0,0 +1,8 @@
+-- counters of the shared rate limit store (RATE_LIMIT_STORE=database);
+-- "if not exists": databases taken as version 1 may have the table already
+create table if not exists rate_limits
+(
+    name         text primary key,
+    window_start integer not null,
+    count        integer not null
+);
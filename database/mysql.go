@@ -0,0 +1,38 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var mysqlDialect = &dialect{
+	name: "mysql",
+	isDuplicate: func(err error) bool {
+		var e *mysql.MySQLError
+		return errors.As(err, &e) && e.Number == 1062 // ER_DUP_ENTRY
+	},
+	quote: func(ident string) string {
+		return "`" + ident + "`"
+	},
+	like: "LIKE ?", // case-insensitive with the default collations
+}
+
+func openMySQL(c Config) (*SQLStore, error) {
+	cfg := mysql.Config{
+		User:                 c.User,
+		Passwd:               c.Password,
+		Net:                  "tcp",
+		Addr:                 c.Addr,
+		DBName:               c.Name,
+		AllowNativePasswords: true,
+	}
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	return newSQLStore(db, mysqlDialect), nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+var postgresDialect = &dialect{
+	name: "postgres",
+	isDuplicate: func(err error) bool {
+		var e *pgconn.PgError
+		return errors.As(err, &e) && e.Code == "23505" // unique_violation
+	},
+	rebind:      rebindNumbered,
+	quote:       quoteIdent,
+	returningID: true,
+	like:        "ILIKE ?",
+}
+
+// rebindNumbered: replace the ? placeholders of query by $1, $2, ...;
+// queries of this package have no ? in literals
+func rebindNumbered(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// openPostgres: connect to the server
+func openPostgres(c Config) (*SQLStore, error) {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   c.Addr,
+		Path:   c.Name,
+	}
+	db, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return newSQLStore(db, postgresDialect), nil
+}
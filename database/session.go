@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
@@ -18,12 +19,33 @@ type Session struct {
 	User       int64
 }
 
+// expired: the session is past its expiry date
+func (s *Session) expired() bool {
+	return s.ExpiryDate <= time.Now().Unix()
+}
+
+// dummyPassHash: compared against on log in attempts with an unknown login,
+// a bcrypt hash with the default cost
+var dummyPassHash = []byte("$2a$10$UOrshxbQmAVUDwNI78VWDeKfQVIExTe8hs04wpspwOmAC5jje8Gjq")
+
+// SessionLifetime: time a session stays valid after log in
+var SessionLifetime = 2 * 24 * time.Hour
+
 const tokenLength = 32
 const tokenAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
 
-func OpenSession(db *sql.DB, login string, pass string) (*Session, error) {
-	user, err := FindUserInfo(db, login)
-	if err != nil {
+// OpenSession: check the password of login and start a new session
+func OpenSession(ctx context.Context, db Store, login string, pass string) (*Session, error) {
+	// unknown login and wrong password are not told apart and take the same
+	// time, so that logins cannot be enumerated
+	user, err := db.FindUserInfo(ctx, login)
+	switch err {
+	case nil:
+		break
+	case ErrNoUser:
+		bcrypt.CompareHashAndPassword(dummyPassHash, []byte(pass))
+		return nil, ErrPassWrong
+	default:
 		return nil, err
 	}
 
@@ -42,20 +64,10 @@ func OpenSession(db *sql.DB, login string, pass string) (*Session, error) {
 	var session = Session{
 		Id:         0,
 		Token:      token,
-		ExpiryDate: time.Now().AddDate(0, 0, 2).Unix(),
+		ExpiryDate: time.Now().Add(SessionLifetime).Unix(),
 		User:       user.Id,
 	}
-	result, err := db.Exec(
-		"INSERT INTO sessions (token, expiry_date, user) VALUES (?,?,?)",
-		session.Token,
-		session.ExpiryDate,
-		session.User,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	session.Id, err = result.LastInsertId()
+	err = db.AddSession(ctx, &session)
 	if err != nil {
 		return nil, err
 	}
@@ -63,10 +75,21 @@ func OpenSession(db *sql.DB, login string, pass string) (*Session, error) {
 	return &session, nil
 }
 
-func CloseSession(db *sql.DB, token []byte) error {
-	result, err := db.Exec(
+func (s *SQLStore) AddSession(ctx context.Context, session *Session) error {
+	var err error
+	session.Id, err = s.insert(ctx,
+		"INSERT INTO sessions (token, expiry_date, "+s.d.quote("user")+") VALUES (?,?,?)",
+		string(session.Token),
+		session.ExpiryDate,
+		session.User,
+	)
+	return err
+}
+
+func (s *SQLStore) CloseSession(ctx context.Context, token []byte) error {
+	result, err := s.q.ExecContext(ctx,
 		"DELETE FROM sessions WHERE token=?",
-		token,
+		string(token),
 	)
 	if err != nil {
 		return err
@@ -83,8 +106,8 @@ func CloseSession(db *sql.DB, token []byte) error {
 	return nil
 }
 
-func VerifySession(db *sql.DB, token []byte) (*Session, error) {
-	row := db.QueryRow("SELECT * FROM sessions WHERE token = ?", token)
+func (s *SQLStore) VerifySession(ctx context.Context, token []byte) (*Session, error) {
+	row := s.q.QueryRowContext(ctx, "SELECT * FROM sessions WHERE token = ?", string(token))
 
 	var session Session
 	err := row.Scan(
@@ -101,5 +124,13 @@ func VerifySession(db *sql.DB, token []byte) (*Session, error) {
 		}
 	}
 
+	if session.expired() {
+		err = s.CloseSession(ctx, token)
+		if err != nil && err != ErrNotLoggedIn {
+			return nil, err
+		}
+		return nil, ErrNotLoggedIn
+	}
+
 	return &session, nil
 }
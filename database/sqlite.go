@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var sqliteDialect = &dialect{
+	name: "sqlite",
+	isDuplicate: func(err error) bool {
+		var e *sqlite.Error
+		return errors.As(err, &e) &&
+			(e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || e.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+	},
+	quote: quoteIdent,
+	like:  "LIKE ? ESCAPE '\\'", // case-insensitive for ASCII
+}
+
+// quoteIdent: standard SQL quoting of a name
+func quoteIdent(ident string) string {
+	return `"` + ident + `"`
+}
+
+// openSQLite: open the database file, creating it if needed
+func openSQLite(c Config) (*SQLStore, error) {
+	pragmas := url.Values{"_pragma": {
+		"foreign_keys(1)",
+		"busy_timeout(5000)", // wait for the writer instead of failing
+		"journal_mode(WAL)",
+	}}
+	db, err := sql.Open("sqlite", "file:"+c.Path+"?"+pragmas.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	return newSQLStore(db, sqliteDialect), nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import "context"
+
+// Store: persistence of users, groups, sessions, objects and tasks.
+// Implementations return the sentinel errors of this package (ErrUserExists,
+// ErrNoUser, ...) for duplicate keys and missing records.
+type Store interface {
+	/* users */
+
+	RegisterUser(ctx context.Context, u *UserInfo) (int64, error)
+	GetUserInfo(ctx context.Context, id int64) (*UserInfo, error)
+	FindUserInfo(ctx context.Context, login string) (*UserInfo, error)
+	UserChangeLogin(ctx context.Context, id int64, newLogin string) error
+	UserChangePasswordHash(ctx context.Context, id int64, passHash []byte) error
+	UserChangeName(ctx context.Context, id int64, nameType int, newName string) error
+	UserSetManagesGroups(ctx context.Context, id int64, managesGroups bool) error
+
+	/* groups */
+
+	GetGroup(ctx context.Context, gid int64) (*Group, error)
+	FindGroup(ctx context.Context, name string) (*Group, error)
+	CreateGroup(ctx context.Context, name string) (*Group, error)
+	RemoveGroup(ctx context.Context, gid int64) error
+	GroupAddUser(ctx context.Context, uid int64, gid int64) error
+	GroupRemoveUser(ctx context.Context, uid int64, gid int64) error
+	ListGroupsOrUsers(ctx context.Context, toList ElementsToList, id int64) ([]int64, error)
+
+	/* sessions, see OpenSession */
+
+	AddSession(ctx context.Context, session *Session) error
+	CloseSession(ctx context.Context, token []byte) error
+	VerifySession(ctx context.Context, token []byte) (*Session, error)
+
+	/* objects */
+
+	AddStruct(ctx context.Context, strct *StructInfo) error
+	GetStructInfo(ctx context.Context, id int64) (*StructInfo, error)
+	FindStructures(ctx context.Context, filter ArgsFStructFind) ([]StructInfo, error)
+	DeleteStruct(ctx context.Context, id int64) error
+	StructChangeName(ctx context.Context, id int64, newName string) error
+	StructChangeDescription(ctx context.Context, id int64, newDescription string) error
+	StructChangeDistrict(ctx context.Context, id int64, newDistrict string) error
+	StructChangeRegion(ctx context.Context, id int64, newRegion string) error
+	StructChangeAddress(ctx context.Context, id int64, newAddress string) error
+	StructChangeType(ctx context.Context, id int64, newType string) error
+	StructChangeState(ctx context.Context, id int64, newState string) error
+	StructChangeArea(ctx context.Context, id int64, newArea int32) error
+	StructChangeOwner(ctx context.Context, id int64, newOwner string) error
+	StructChangeActualUser(ctx context.Context, id int64, newActualUser string) error
+	StructChangePermissions(ctx context.Context, id int64, newPermission int8) error
+
+	/* tasks */
+
+	CreateTask(ctx context.Context, task *Task) (int64, error)
+	RemoveTask(ctx context.Context, id int64) error
+	GetTask(ctx context.Context, id int64) (*Task, error)
+	FilterTasks(ctx context.Context, filter *TaskFilter) ([]*Task, error)
+
+	/* maintenance */
+
+	// WithTx: run fn on a store whose changes are committed if fn returns nil
+	// and rolled back otherwise; calls inside a transaction join it
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+	Ping(ctx context.Context) error
+	CheckSchema(ctx context.Context) error
+	Close() error
+}
@@ -1,12 +1,9 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"strconv"
-
-	"github.com/go-sql-driver/mysql"
 )
 
 var ErrStructExists = errors.New("struct already exists")
@@ -38,18 +35,19 @@ type ArgsFStructFind struct {
 	Address     string
 	Type        string
 	State       string
-	AreaFrom    *int32
-	AreaTo      *int32
+	AreaFrom    *int32 `validate:"min=0"`
+	AreaTo      *int32 `validate:"min=0"`
 	Owner       string
 	Actual_user string
 	Gid         *int64
-	Limit       int16
+	Limit       int16 `validate:"min=1,max=1000"`
 	SortAsc     bool
-	Offset      int16
+	Offset      int16 `validate:"min=0"`
 }
 
-func (strct *StructInfo) AddStruct(db *sql.DB) error {
-	result, err := db.Exec(
+func (s *SQLStore) AddStruct(ctx context.Context, strct *StructInfo) error {
+	var err error
+	strct.Id, err = s.insert(ctx,
 		"INSERT INTO objects (name, description, district, region, address, type, state, area, owner, actual_user, gid, permissions) VALUES (?,?,?,?,?,?,?,?,?,?,?,?);",
 		strct.Name, strct.Description, strct.District, strct.Region,
 		strct.Address, strct.Type, strct.State, strct.Area,
@@ -57,25 +55,14 @@ func (strct *StructInfo) AddStruct(db *sql.DB) error {
 		strct.Permissions,
 	)
 	if err != nil {
-		switch e := err.(type) {
-		case *mysql.MySQLError:
-			if e.Number == 1062 {
-				return ErrStructExists
-			}
-		}
-		return err
-	}
-
-	strct.Id, err = result.LastInsertId()
-	if err != nil {
-		return err
+		return s.duplicate(err, ErrStructExists)
 	}
 
 	return nil
 }
 
-func GetStructInfo(db *sql.DB, id int64) (*StructInfo, error) {
-	row := db.QueryRow("SELECT * FROM objects WHERE id = ?;", id)
+func (s *SQLStore) GetStructInfo(ctx context.Context, id int64) (*StructInfo, error) {
+	row := s.q.QueryRowContext(ctx, "SELECT * FROM objects WHERE id = ?;", id)
 
 	var strct StructInfo
 	if err := row.Scan(
@@ -103,49 +90,56 @@ func GetStructInfo(db *sql.DB, id int64) (*StructInfo, error) {
 	return &strct, nil
 }
 
-func FindStructures(db *sql.DB, filter ArgsFStructFind) ([]StructInfo, error) {
-	query := "SELECT * FROM objects "
-	var params []string
+func (s *SQLStore) FindStructures(ctx context.Context, filter ArgsFStructFind) ([]StructInfo, error) {
+	query := "SELECT * FROM objects"
+	var conds []string
+	var args []interface{}
+	cond := func(c string, arg interface{}) {
+		conds = append(conds, c)
+		args = append(args, arg)
+	}
 	if filter.Name != "" {
-		params = append(params, "name = \""+filter.Name+"\"")
+		cond("name = ?", filter.Name)
 	}
 	if filter.Description != "" {
-		params = append(params, "desciprtion = \""+filter.Description+"\"")
+		cond("description = ?", filter.Description)
 	}
 	if filter.District != "" {
-		params = append(params, "district = \""+filter.District+"\"")
+		cond("district = ?", filter.District)
 	}
 	if filter.Region != "" {
-		params = append(params, "region = \""+filter.Region+"\"")
+		cond("region = ?", filter.Region)
 	}
 	if filter.Address != "" {
-		params = append(params, "address = \""+filter.Address+"\"")
+		cond("address = ?", filter.Address)
 	}
 	if filter.Type != "" {
-		params = append(params, "type = \""+filter.Type+"\"")
+		cond("type = ?", filter.Type)
 	}
 	if filter.State != "" {
-		params = append(params, "state= \""+filter.State+"\"")
+		cond("state = ?", filter.State)
 	}
 	if filter.AreaFrom != nil && filter.AreaTo != nil {
-		params = append(params, "area >= "+strconv.FormatInt(int64(*filter.AreaFrom), 10)+" and "+"area <= "+strconv.FormatInt(int64(*filter.AreaTo), 10))
+		cond("area >= ?", *filter.AreaFrom)
+		cond("area <= ?", *filter.AreaTo)
 	} else if filter.AreaTo != nil {
-		params = append(params, "area <"+strconv.FormatInt(int64(*filter.AreaTo), 10))
+		cond("area < ?", *filter.AreaTo)
 	} else if filter.AreaFrom != nil {
-		params = append(params, "area > "+strconv.FormatInt(int64(*filter.AreaFrom), 10))
+		cond("area > ?", *filter.AreaFrom)
 	}
 	if filter.Gid != nil {
-		params = append(params, "gid = "+strconv.FormatInt(int64(*filter.Gid), 10))
+		cond("gid = ?", *filter.Gid)
 	}
-	for i := 0; i < len(params); i++ {
+	for i := 0; i < len(conds); i++ {
 		if i == 0 {
-			query += " WHERE " + params[i]
+			query += " WHERE " + conds[i]
 		} else {
-			query += " AND " + params[i]
+			query += " AND " + conds[i]
 		}
 	}
-	fmt.Println(query)
-	rows, err := db.Query(query + " LIMIT " + strconv.FormatInt(int64(filter.Limit), 10) + " OFFSET " + strconv.FormatInt(int64(filter.Offset), 10))
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+	rows, err := s.q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -180,19 +174,27 @@ func FindStructures(db *sql.DB, filter ArgsFStructFind) ([]StructInfo, error) {
 
 }
 
-func DeleteStruct(db *sql.DB, Id int64) error {
-	_, err := db.Exec(
+func (s *SQLStore) DeleteStruct(ctx context.Context, Id int64) error {
+	result, err := s.q.ExecContext(ctx,
 		"DELETE FROM objects WHERE id=?;",
 		Id,
 	)
 	if err != nil {
 		return err
 	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return ErrNoStruct
+	}
 	return nil
 }
 
-func StructChangeName(db *sql.DB, id int64, newName string) error {
-	result, err := db.Exec("UPDATE objects SET name=? WHERE id=?;", newName, id)
+func (s *SQLStore) StructChangeName(ctx context.Context, id int64, newName string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET name=? WHERE id=?;", newName, id)
 	switch err {
 	case nil:
 		break
@@ -209,8 +211,8 @@ func StructChangeName(db *sql.DB, id int64, newName string) error {
 	return nil
 }
 
-func StructChangeDescription(db *sql.DB, id int64, newDescription string) error {
-	result, err := db.Exec("UPDATE objects SET description=? WHERE id=?;", newDescription, id)
+func (s *SQLStore) StructChangeDescription(ctx context.Context, id int64, newDescription string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET description=? WHERE id=?;", newDescription, id)
 	switch err {
 	case nil:
 		break
@@ -227,8 +229,8 @@ func StructChangeDescription(db *sql.DB, id int64, newDescription string) error
 	return nil
 }
 
-func StructChangeDistrict(db *sql.DB, id int64, newDistrict string) error {
-	result, err := db.Exec("UPDATE objects SET district=? WHERE id=?;", newDistrict, id)
+func (s *SQLStore) StructChangeDistrict(ctx context.Context, id int64, newDistrict string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET district=? WHERE id=?;", newDistrict, id)
 	switch err {
 	case nil:
 		break
@@ -245,8 +247,8 @@ func StructChangeDistrict(db *sql.DB, id int64, newDistrict string) error {
 	return nil
 }
 
-func StructChangeRegion(db *sql.DB, id int64, newRegion string) error {
-	result, err := db.Exec("UPDATE objects SET region=? WHERE id=?;", newRegion, id)
+func (s *SQLStore) StructChangeRegion(ctx context.Context, id int64, newRegion string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET region=? WHERE id=?;", newRegion, id)
 	switch err {
 	case nil:
 		break
@@ -263,8 +265,8 @@ func StructChangeRegion(db *sql.DB, id int64, newRegion string) error {
 	return nil
 }
 
-func StructChangeAddress(db *sql.DB, id int64, newAddress string) error {
-	result, err := db.Exec("UPDATE objects SET address=? WHERE id=?;", newAddress, id)
+func (s *SQLStore) StructChangeAddress(ctx context.Context, id int64, newAddress string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET address=? WHERE id=?;", newAddress, id)
 	switch err {
 	case nil:
 		break
@@ -281,8 +283,8 @@ func StructChangeAddress(db *sql.DB, id int64, newAddress string) error {
 	return nil
 }
 
-func StructChangeType(db *sql.DB, id int64, newType string) error {
-	result, err := db.Exec("UPDATE objects SET type=? WHERE id=?;", newType, id)
+func (s *SQLStore) StructChangeType(ctx context.Context, id int64, newType string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET type=? WHERE id=?;", newType, id)
 	switch err {
 	case nil:
 		break
@@ -299,8 +301,8 @@ func StructChangeType(db *sql.DB, id int64, newType string) error {
 	return nil
 }
 
-func StructChangeState(db *sql.DB, id int64, newState string) error {
-	result, err := db.Exec("UPDATE objects SET state=? WHERE id=?;", newState, id)
+func (s *SQLStore) StructChangeState(ctx context.Context, id int64, newState string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET state=? WHERE id=?;", newState, id)
 	switch err {
 	case nil:
 		break
@@ -317,8 +319,8 @@ func StructChangeState(db *sql.DB, id int64, newState string) error {
 	return nil
 }
 
-func StructChangeArea(db *sql.DB, id int64, newArea int32) error {
-	result, err := db.Exec("UPDATE objects SET area=? WHERE id=?;", newArea, id)
+func (s *SQLStore) StructChangeArea(ctx context.Context, id int64, newArea int32) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET area=? WHERE id=?;", newArea, id)
 	switch err {
 	case nil:
 		break
@@ -335,8 +337,8 @@ func StructChangeArea(db *sql.DB, id int64, newArea int32) error {
 	return nil
 }
 
-func StructChangeOwner(db *sql.DB, id int64, newOwner string) error {
-	result, err := db.Exec("UPDATE objects SET owner=? WHERE id=?;", newOwner, id)
+func (s *SQLStore) StructChangeOwner(ctx context.Context, id int64, newOwner string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET owner=? WHERE id=?;", newOwner, id)
 	switch err {
 	case nil:
 		break
@@ -353,8 +355,8 @@ func StructChangeOwner(db *sql.DB, id int64, newOwner string) error {
 	return nil
 }
 
-func StructChangeActualUser(db *sql.DB, id int64, newActualUser string) error {
-	result, err := db.Exec("UPDATE objects SET actual_user=? WHERE id=?;", newActualUser, id)
+func (s *SQLStore) StructChangeActualUser(ctx context.Context, id int64, newActualUser string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET actual_user=? WHERE id=?;", newActualUser, id)
 	switch err {
 	case nil:
 		break
@@ -371,12 +373,12 @@ func StructChangeActualUser(db *sql.DB, id int64, newActualUser string) error {
 	return nil
 }
 
-func StructChangePermissions(db *sql.DB, id int64, newPermission int8) error {
+func (s *SQLStore) StructChangePermissions(ctx context.Context, id int64, newPermission int8) error {
 	if newPermission > 63 {
 		return ErrBigPermission
 	}
 
-	result, err := db.Exec("UPDATE objects SET permissions=? WHERE id=?;", newPermission, id)
+	result, err := s.q.ExecContext(ctx, "UPDATE objects SET permissions=? WHERE id=?;", newPermission, id)
 
 	switch err {
 	case nil:
@@ -1,9 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql"
+	"strings"
 )
 
 var ErrTaskExists = errors.New("task already exists")
@@ -37,8 +38,8 @@ type TaskFilter struct {
 	Offset int16
 }
 
-func CreateTask(db *sql.DB, task *Task) (int64, error) {
-	result, err := db.Exec(
+func (s *SQLStore) CreateTask(ctx context.Context, task *Task) (int64, error) {
+	id, err := s.insert(ctx,
 		"INSERT INTO tasks(name,description,deadline,status,object,maintainer,gid,permissions) VALUES(?,?,?,?,?,?,?,?);",
 		task.Name,
 		task.Description,
@@ -50,25 +51,14 @@ func CreateTask(db *sql.DB, task *Task) (int64, error) {
 		task.Permissions,
 	)
 	if err != nil {
-		switch e := err.(type) {
-		case *mysql.MySQLError:
-			if e.Number == 1062 {
-				return 0, ErrTaskExists
-			}
-		}
-		return 0, err
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
+		return 0, s.duplicate(err, ErrTaskExists)
 	}
 
 	return id, nil
 }
 
-func RemoveTask(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM tasks WHERE id=?;", id)
+func (s *SQLStore) RemoveTask(ctx context.Context, id int64) error {
+	result, err := s.q.ExecContext(ctx, "DELETE FROM tasks WHERE id=?;", id)
 	if err != nil {
 		return err
 	}
@@ -84,8 +74,8 @@ func RemoveTask(db *sql.DB, id int64) error {
 	return err
 }
 
-func GetTask(db *sql.DB, id int64) (*Task, error) {
-	row := db.QueryRow("SELECT * FROM tasks WHERE id=?;", id)
+func (s *SQLStore) GetTask(ctx context.Context, id int64) (*Task, error) {
+	row := s.q.QueryRowContext(ctx, "SELECT * FROM tasks WHERE id=?;", id)
 
 	var task Task
 	err := row.Scan(
@@ -110,38 +100,46 @@ func GetTask(db *sql.DB, id int64) (*Task, error) {
 	return &task, nil
 }
 
-func FilterTasks(db *sql.DB, filter *TaskFilter) ([]*Task, error) {
-	rows, err := db.Query(`SELECT * FROM tasks
-	    WHERE ((name LIKE ?) OR ? IS NULL)
-	      AND ((description LIKE ?) OR ? IS NULL)
-	      AND ((deadline >= ?) OR ? IS NULL)
-	      AND ((deadline <= ?) OR ? IS NULL)
-	      AND ((status LIKE ?) OR ? IS NULL)
-	      AND ((object = ?) OR ? IS NULL)
-	      AND ((maintainer = ?) OR ? IS NULL)
-	      AND ((Gid = ?) OR ? IS NULL)
-	    LIMIT ? OFFSET ?;`,
-
-		filter.Name,
-		filter.Name,
-		filter.Description,
-		filter.Description,
-		filter.DeadlineFrom,
-		filter.DeadlineFrom,
-		filter.DeadlineTo,
-		filter.DeadlineTo,
-		filter.Status,
-		filter.Status,
-		filter.Object,
-		filter.Object,
-		filter.Maintainer,
-		filter.Maintainer,
-		filter.Gid,
-		filter.Gid,
-
-		filter.Limit,
-		filter.Offset,
-	)
+func (s *SQLStore) FilterTasks(ctx context.Context, filter *TaskFilter) ([]*Task, error) {
+	// only the set fields are compared, a parameter alone in "? IS NULL"
+	// has no type in postgres
+	query := "SELECT * FROM tasks"
+	var conds []string
+	var args []interface{}
+	cond := func(c string, arg interface{}) {
+		conds = append(conds, c)
+		args = append(args, arg)
+	}
+	if filter.Name != nil {
+		cond("name "+s.d.like, *filter.Name)
+	}
+	if filter.Description != nil {
+		cond("description "+s.d.like, *filter.Description)
+	}
+	if filter.DeadlineFrom != nil {
+		cond("deadline >= ?", *filter.DeadlineFrom)
+	}
+	if filter.DeadlineTo != nil {
+		cond("deadline <= ?", *filter.DeadlineTo)
+	}
+	if filter.Status != nil {
+		cond("status "+s.d.like, *filter.Status)
+	}
+	if filter.Object != nil {
+		cond("object = ?", *filter.Object)
+	}
+	if filter.Maintainer != nil {
+		cond("maintainer = ?", *filter.Maintainer)
+	}
+	if filter.Gid != nil {
+		cond("gid = ?", *filter.Gid)
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " LIMIT ? OFFSET ?;"
+	args = append(args, filter.Limit, filter.Offset)
+	rows, err := s.q.QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return nil, err
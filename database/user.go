@@ -1,11 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-
-	"github.com/go-sql-driver/mysql"
 )
 
 var ErrUserExists = errors.New("user already exists")
@@ -38,38 +37,21 @@ func (u UserInfo) Format() string {
 	)
 }
 
-func (u UserInfo) Register(db *sql.DB) error {
-	var err error
-	u.Id, err = RegisterUser(db, &u)
-	return err
-}
-
-func RegisterUser(db *sql.DB, u *UserInfo) (int64, error) {
+func (s *SQLStore) RegisterUser(ctx context.Context, u *UserInfo) (int64, error) {
 	q := "INSERT INTO users (login, pass_hash, first_name, last_name, patronymic, manages_groups) VALUES (?,?,?,?,?,?);"
-	result, err := db.Exec(
+	id, err := s.insert(ctx,
 		q,
 		u.Login, u.PassHash, u.FirstName, u.LastName, u.Patronymic, u.ManagesGroups,
 	)
 	if err != nil {
-		switch e := err.(type) {
-		case *mysql.MySQLError:
-			if e.Number == 1062 {
-				return 0, ErrUserExists
-			}
-		}
-		return 0, err
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
+		return 0, s.duplicate(err, ErrUserExists)
 	}
 
 	return id, nil
 }
 
-func GetUserInfo(db *sql.DB, id int64) (*UserInfo, error) {
-	row := db.QueryRow("SELECT * FROM users WHERE id = ?;", id)
+func (s *SQLStore) GetUserInfo(ctx context.Context, id int64) (*UserInfo, error) {
+	row := s.q.QueryRowContext(ctx, "SELECT * FROM users WHERE id = ?;", id)
 
 	var user UserInfo
 	if err := row.Scan(
@@ -91,8 +73,8 @@ func GetUserInfo(db *sql.DB, id int64) (*UserInfo, error) {
 	return &user, nil
 }
 
-func FindUserInfo(db *sql.DB, login string) (*UserInfo, error) {
-	row := db.QueryRow("SELECT * FROM users WHERE login = ?;", login)
+func (s *SQLStore) FindUserInfo(ctx context.Context, login string) (*UserInfo, error) {
+	row := s.q.QueryRowContext(ctx, "SELECT * FROM users WHERE login = ?;", login)
 
 	var user UserInfo
 	if err := row.Scan(
@@ -114,17 +96,10 @@ func FindUserInfo(db *sql.DB, login string) (*UserInfo, error) {
 	return &user, nil
 }
 
-func UserChangeLogin(db *sql.DB, id int64, newLogin string) error {
-	result, err := db.Exec("UPDATE users SET login=? WHERE id=?;", newLogin, id)
-	switch e := err.(type) {
-	case nil:
-		break
-	case *mysql.MySQLError:
-		if e.Number == 1062 {
-			return ErrUserExists
-		}
-	default:
-		return err
+func (s *SQLStore) UserChangeLogin(ctx context.Context, id int64, newLogin string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE users SET login=? WHERE id=?;", newLogin, id)
+	if err != nil {
+		return s.duplicate(err, ErrUserExists)
 	}
 
 	n, err := result.RowsAffected()
@@ -138,8 +113,8 @@ func UserChangeLogin(db *sql.DB, id int64, newLogin string) error {
 	return nil
 }
 
-func UserChangePasswordHash(db *sql.DB, id int64, pass_hash []byte) error {
-	result, err := db.Exec("UPDATE users SET pass_hash=? WHERE id=?;", pass_hash, id)
+func (s *SQLStore) UserChangePasswordHash(ctx context.Context, id int64, passHash []byte) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE users SET pass_hash=? WHERE id=?;", passHash, id)
 	if err != nil {
 		return err
 	}
@@ -155,13 +130,13 @@ func UserChangePasswordHash(db *sql.DB, id int64, pass_hash []byte) error {
 	return nil
 }
 
-func UserChangeName(db *sql.DB, id int64, nameType int, newName string) error {
+func (s *SQLStore) UserChangeName(ctx context.Context, id int64, nameType int, newName string) error {
 	var nameTypes = [3]string{"first_name", "last_name", "patronymic"}
 	if nameType >= len(nameTypes) || nameType < 0 {
 		return fmt.Errorf("invalid name type")
 	}
 
-	result, err := db.Exec("UPDATE users SET "+nameTypes[nameType]+"=? WHERE id=?;", newName, id)
+	result, err := s.q.ExecContext(ctx, "UPDATE users SET "+nameTypes[nameType]+"=? WHERE id=?;", newName, id)
 	if err != nil {
 		return err
 	}
@@ -177,8 +152,8 @@ func UserChangeName(db *sql.DB, id int64, nameType int, newName string) error {
 	return nil
 }
 
-func UserSetManagesGroups(db *sql.DB, id int64, managesGroups bool) error {
-	result, err := db.Exec("UPDATE users SET manages_groups=? WHERE id=?;", managesGroups, id)
+func (s *SQLStore) UserSetManagesGroups(ctx context.Context, id int64, managesGroups bool) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE users SET manages_groups=? WHERE id=?;", managesGroups, id)
 	if err != nil {
 		return err
 	}
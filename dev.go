@@ -0,0 +1,120 @@
+package main
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"log/slog"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// devPassword: password of the demo users of the development mode
+const devPassword = "demo-password"
+
+// devPermissions: user manage, group edit, others read
+const devPermissions = 3<<4 | 2<<2 | 1
+
+// devStore: in-memory store with demo users, groups, objects and tasks
+func devStore() (database.Store, error) {
+	ctx := context.Background()
+	db := database.NewMemoryStore()
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(devPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []database.UserInfo{
+		{Login: "admin", FirstName: "Админ", LastName: "Демо", ManagesGroups: true},
+		{Login: "ivanov", FirstName: "Иван", LastName: "Иванов", Patronymic: "Петрович"},
+		{Login: "petrova", FirstName: "Мария", LastName: "Петрова", Patronymic: "Сергеевна"},
+	}
+	uids := make(map[string]int64, len(users))
+	for _, u := range users {
+		u.PassHash = passHash
+		uids[u.Login], err = db.RegisterUser(ctx, &u)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	members := map[string][]string{
+		"maintenance": {"admin", "ivanov"},
+		"accounting":  {"admin", "petrova"},
+	}
+	gids := make(map[string]int64, len(members))
+	for _, name := range []string{"maintenance", "accounting"} {
+		group, err := db.CreateGroup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		gids[name] = group.Id
+		for _, login := range members[name] {
+			err = db.GroupAddUser(ctx, uids[login], group.Id)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	objects := []database.StructInfo{
+		{
+			Name: "Бизнес-центр «Тверской»", Description: "Офисное здание, 6 этажей",
+			District: "ЦАО", Region: "Тверской", Address: "ул. Тверская, 7",
+			Type: "office", State: "in use", Area: 4200,
+			Owner: "ООО «Эстейт»", Actual_user: "ООО «Эстейт»",
+			Gid: gids["maintenance"], Permissions: devPermissions,
+		},
+		{
+			Name: "Склад №3", Description: "Складское помещение",
+			District: "ЮАО", Region: "Нагатино-Садовники", Address: "Варшавское ш., 47",
+			Type: "warehouse", State: "under repair", Area: 1800,
+			Owner: "ООО «Эстейт»", Actual_user: "АО «Логистика»",
+			Gid: gids["maintenance"], Permissions: devPermissions,
+		},
+		{
+			Name: "Торговое помещение", Description: "Первый этаж жилого дома",
+			District: "САО", Region: "Аэропорт", Address: "Ленинградский пр-т, 62",
+			Type: "retail", State: "vacant", Area: 150,
+			Owner: "ООО «Эстейт»", Actual_user: "",
+			Gid: gids["accounting"], Permissions: devPermissions,
+		},
+	}
+	for i := range objects {
+		err = db.AddStruct(ctx, &objects[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	week := time.Now().Add(7 * 24 * time.Hour).Unix()
+	tasks := []database.Task{
+		{
+			Name: "Проверить кровлю", Description: "Осмотр после зимы",
+			Deadline: week, Status: "open", Object: objects[0].Id,
+			Maintainer: uids["ivanov"], Gid: gids["maintenance"], Permissions: devPermissions,
+		},
+		{
+			Name: "Заменить ворота", Description: "Ворота погрузочной зоны",
+			Deadline: week, Status: "in progress", Object: objects[1].Id,
+			Maintainer: uids["ivanov"], Gid: gids["maintenance"], Permissions: devPermissions,
+		},
+		{
+			Name: "Найти арендатора", Description: "Подготовить объявление",
+			Deadline: week, Status: "open", Object: objects[2].Id,
+			Maintainer: uids["petrova"], Gid: gids["accounting"], Permissions: devPermissions,
+		},
+	}
+	for i := range tasks {
+		_, err = db.CreateTask(ctx, &tasks[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Warn("development mode: in-memory database, changes are lost on exit",
+		"users", len(users), "password", devPassword)
+
+	return db, nil
+}
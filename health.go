@@ -0,0 +1,55 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const readyTimeout = 2 * time.Second
+
+// readiness: result of the readiness checks, "ok" or a short reason;
+// the errors are logged, not sent to the client
+type readiness struct {
+	Database string
+	Schema   string
+}
+
+// healthzHandler: the process is alive
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+// readyzHandler: the instance can serve requests: the database is reachable
+// and has the schema
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	status := readiness{Database: "ok", Schema: "ok"}
+	var err error
+	if err = api.Db.Ping(ctx); err != nil {
+		status.Database = "unavailable"
+		status.Schema = "unknown"
+	} else if err = api.Db.CheckSchema(ctx); errors.Is(err, database.ErrSchemaOld) {
+		status.Schema = "pending migrations"
+	} else if err != nil {
+		status.Schema = "unavailable"
+	}
+
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusServiceUnavailable
+		slog.Warn("not ready", "database", status.Database, "schema", status.Schema, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
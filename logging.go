@@ -0,0 +1,115 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/config"
+	"BastetSoftware/backend/database"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// newLogger: logger writing to w in the configured format and level
+func newLogger(w io.Writer, c config.Log) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level)) // checked by config.Validate
+
+	opts := &slog.HandlerOptions{Level: level}
+	if c.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// fatal: log an error and exit
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type requestIDKey struct{}
+
+// requestID: id of the request of ctx, empty if none
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// newRequestID: random request id
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID: id set by a proxy in front of the server, short and printable
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestIDs: give every request an id, echoed in the X-Request-Id header;
+// an id sent by the client or a proxy is kept
+func withRequestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(withRequestID(r.Context(), id)))
+	})
+}
+
+// logCall: log every call with its result; arguments are not logged,
+// they hold passwords and tokens
+func logCall(f *api.Function, handler api.RequestHandler) api.RequestHandler {
+	name := f.Name
+	return func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		info := &api.CallInfo{}
+		start := time.Now()
+		response, err := handler(api.WithCallInfo(ctx, info), db, r)
+		latency := time.Since(start)
+
+		code := api.ResponseCode(response)
+		level := slog.LevelInfo
+		if err != nil || code == api.EUnknown {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID(ctx)),
+			slog.String("function", name),
+			slog.String("version", api.VersionOf(ctx).Name),
+			slog.Int("code", int(code)),
+			slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+		}
+		if info.Uid != 0 {
+			attrs = append(attrs, slog.Int64("uid", info.Uid))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		slog.LogAttrs(ctx, level, "call", attrs...)
+
+		return response, err
+	}
+}
@@ -2,23 +2,35 @@ package main
 
 import (
 	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/config"
 	"BastetSoftware/backend/database"
+	"BastetSoftware/backend/ratelimit"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/vmihailenco/msgpack/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var origin string
+var shutdownTimeout = 30 * time.Second // time to finish calls in flight on shutdown
+var maxBodySize int64 = 1 << 20        // request body limit, larger requests fail with ETooLarge
 
-func writeResponse(w http.ResponseWriter, v interface{}) error {
-	data, err := msgpack.Marshal(v)
+func writeResponse(w http.ResponseWriter, c codec, v interface{}) error {
+	data, err := c.encode(v)
 	if err != nil {
 		return err
 	}
 
+	w.Header().Set("Content-Type", c.ContentType())
 	_, err = w.Write(data)
 	if err != nil {
 		return err
@@ -27,86 +39,231 @@ func writeResponse(w http.ResponseWriter, v interface{}) error {
 	return nil
 }
 
-func apiHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", origin)
+// versionHeaders: announce the deprecation of v
+func versionHeaders(h http.Header, v *api.Version) {
+	if !v.Deprecated {
+		return
+	}
+
+	h.Set("Deprecation", "true")
+	if v.Sunset != "" {
+		h.Set("Sunset", v.Sunset)
+	}
+	if v.Successor != "" {
+		h.Set("Link", "</api/"+v.Successor+"/>; rel=\"successor-version\"")
+	}
+}
+
+// apiHandler: HTTP transport of the functions of v, prefix is followed by the function name
+func apiHandler(v *api.Version, prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versionHeaders(w.Header(), v)
+		apiCall(w, r, v, r.URL.Path[len(prefix):])
+	}
+}
+
+// tooLargeResponse: response to a request over maxBodySize
+func tooLargeResponse() api.Response {
+	return api.Response{
+		Code: api.ETooLarge,
+		Error: &api.ErrorDetail{
+			Key:     "too_large",
+			Message: fmt.Sprintf("request body is larger than %d bytes", maxBodySize),
+		},
+	}
+}
 
-	var buf []byte
-	var n int
-	handler := apiFHandlers[r.URL.Path[len("/api/"):]]
-	if handler == nil {
+func apiCall(w http.ResponseWriter, r *http.Request, v *api.Version, name string) {
+	reqCodec := requestCodec(r)
+	respCodec := responseCodec(r, reqCodec)
+
+	var body []byte
+	var handler api.RequestHandler
+	if f := v.Lookup(name); f == nil {
 		// unknown API function, no arguments
 		handler = api.UnknownFPlug
-		buf = nil
-		n = 0
 	} else {
+		handler = f.Handler
+
 		// valid API function, read request body
-		buf = make([]byte, 4096)
 		var err error
-		n, err = r.Body.Read(buf)
-		if err != nil && err != io.EOF {
-			log.Fatal(err)
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case err == nil:
+			break
+		case errors.As(err, &tooLarge):
+			err = writeResponse(w, respCodec, tooLargeResponse())
+			if err != nil {
+				slog.DebugContext(r.Context(), "cannot write response", "request_id", requestID(r.Context()), "error", err)
+			}
+			return
+		default:
+			// client went away
+			slog.DebugContext(r.Context(), "cannot read request", "request_id", requestID(r.Context()), "error", err)
+			return
 		}
 	}
 
-	response, err := handler(buf[:n])
+	var response interface{}
+	args, err := reqCodec.decodeArgs(body)
 	if err != nil {
-		log.Println(err)
+		slog.DebugContext(r.Context(), "malformed request body", "request_id", requestID(r.Context()), "function", name, "error", err)
+		response = api.Response{
+			Code:  api.EArgsInval,
+			Error: &api.ErrorDetail{Key: "args.malformed", Message: "malformed request body: " + err.Error()},
+		}
+	} else {
+		ctx, cancel := handlerContext(api.WithVersion(withClientIP(r.Context(), r), v), name)
+		response, _ = handler(ctx, api.Db, args) // logged by logCall
+		cancel()
 	}
 
-	err = writeResponse(w, response)
+	err = writeResponse(w, respCodec, api.WithErrorDetail(response))
 	if err != nil {
-		log.Println(err)
+		slog.DebugContext(r.Context(), "cannot write response", "request_id", requestID(r.Context()), "error", err)
 	}
 }
 
-var apiFHandlers map[string]api.RequestHandler
-
 func main() {
-	var err error
+	flags := config.RegisterFlags(flag.CommandLine)
+	dev := flag.Bool("dev", false, "development mode: in-memory database with demo data")
+	flag.Parse()
 
-	if o := os.Getenv("RESPONSE_ORIGIN"); o != "" {
-		origin = o
-	} else {
-		origin = "*"
+	cfg, err := flags.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	/* setup handlers */
+	var logOut io.Writer = os.Stderr
+	if cfg.Log.File != "" {
+		f, err := os.OpenFile(cfg.Log.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		logOut = f
+	}
+	slog.SetDefault(newLogger(logOut, cfg.Log))
 
-	apiFHandlers = make(map[string]api.RequestHandler)
+	cors.Origins = cfg.CORS.Origins
+	cors.Methods = cfg.CORS.Methods
+	cors.Headers = cfg.CORS.Headers
+	cors.Credentials = cfg.CORS.Credentials
+	cors.MaxAge = cfg.CORS.MaxAge
 
-	apiFHandlers["ping"] = api.HandleFPing
+	requestTimeout = cfg.Server.RequestTimeout
+	apiFTimeouts = cfg.Server.RequestTimeouts
+	shutdownTimeout = cfg.Server.ShutdownTimeout
+	maxBodySize = cfg.Server.MaxBodySize
+	trustedProxies, _ = config.ParseNetworks(cfg.Server.TrustedProxies) // checked by Validate
 
-	apiFHandlers["user_create"] = api.HandleFUserCreate
-	apiFHandlers["user_log_in"] = api.HandleFLogIn
-	apiFHandlers["user_log_out"] = api.HandleFLogOut
-	apiFHandlers["user_get_info"] = api.HandleFUserInfo
-	apiFHandlers["user_edit"] = api.HandleFUserEdit
-	apiFHandlers["user_set_manages_groups"] = api.HandleFUserSetManagesGroups
-	apiFHandlers["user_list_groups"] = api.HandleFUserListGroups
+	loginIPLimit = cfg.RateLimit.LoginIP
+	loginLimit = cfg.RateLimit.Login
+	tokenLimit = cfg.RateLimit.Token
 
-	apiFHandlers["group_create"] = api.HandleFGroupCreate
-	apiFHandlers["group_remove"] = api.HandleFGroupRemove
-	apiFHandlers["group_add_remove_user"] = api.HandleFGroupAddRemoveUser
-	apiFHandlers["group_get_info"] = api.HandleFGroupGetInfo
+	database.SessionLifetime = cfg.Session.Lifetime
 
-	apiFHandlers["object_create"] = api.HandleFStructCreate
-	apiFHandlers["object_get_info"] = api.HandleFStructInfo
-	apiFHandlers["find_object"] = api.HandleFStructFind
-	apiFHandlers["object_delete"] = api.HandleFDeleteStruct
-	apiFHandlers["object_change"] = api.HandleFStructEdit
+	// clients of deprecated versions are pointed to the newest one
+	for _, name := range cfg.API.Deprecated {
+		v := api.LookupVersion(name)
+		v.Deprecated = true
+		v.Successor = api.Versions[len(api.Versions)-1].Name
+	}
+
+	/* setup handlers */
 
-	apiFHandlers["task_create"] = api.HandleFTaskCreate
-	apiFHandlers["task_remove"] = api.HandleFTaskRemove
-	apiFHandlers["task_get_info"] = api.HandleFTaskGetInfo
-	apiFHandlers["task_search"] = api.HandleFTaskSearch
+	api.Wrap(recoverPanic)
+	api.Wrap(rateLimit)
+	api.Wrap(instrument)
+	api.Wrap(logCall)
+
+timeouts:
+	for name := range apiFTimeouts {
+		for _, v := range api.Versions {
+			if v.Lookup(name) != nil {
+				continue timeouts
+			}
+		}
+		fatal("unknown API function in Server.RequestTimeouts", "function", name)
+	}
 
 	/* =(setup handlers)= */
 
-	api.Db, err = database.OpenDB()
+	if *dev {
+		api.Db, err = devStore()
+	} else {
+		api.Db, err = database.Open(cfg.Database)
+	}
 	if err != nil {
-		log.Fatal(err)
+		fatal("cannot open database", "error", err)
+	}
+	if err = api.Db.CheckSchema(context.Background()); err != nil {
+		fatal("cannot use database", "error", err)
+	}
+	if sqlStore, ok := api.Db.(*database.SQLStore); ok {
+		registerDBMetrics(sqlStore.DB())
+
+		if cfg.RateLimit.Store == "database" {
+			rateStore, err = ratelimit.NewSQLStore(sqlStore.DB(), sqlStore.Driver())
+			if err != nil {
+				fatal("cannot set up rate limits", "error", err)
+			}
+		}
+	} else if cfg.RateLimit.Store == "database" {
+		slog.Warn("rate limits are kept in memory, the database has no rate_limits table")
+	}
+
+	// unversioned paths are v1
+	http.Handle("/api/", withCORS(apiHandler(api.V1, "/api/")))
+	http.HandleFunc("/ws", wsHandler(api.V1))
+	for _, v := range api.Versions {
+		http.Handle("/api/"+v.Name+"/", withCORS(apiHandler(v, "/api/"+v.Name+"/")))
+		http.HandleFunc("/ws/"+v.Name, wsHandler(v))
+	}
+	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+
+	srv := &http.Server{Addr: cfg.Server.Listen, Handler: withRequestIDs(http.DefaultServeMux)}
+	srv.RegisterOnShutdown(wsClose)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.Server.TLSCert != "" {
+			serveErr <- srv.ListenAndServeTLS(cfg.Server.TLSCert, cfg.Server.TLSKey)
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+
+	slog.Info("listening", "addr", cfg.Server.Listen, "tls", cfg.Server.TLSCert != "")
+
+	select {
+	case err = <-serveErr:
+		fatal("cannot serve", "error", err)
+	case <-ctx.Done():
 	}
 
-	http.HandleFunc("/api/", apiHandler)
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	/* shutdown: stop accepting connections, let calls in flight finish */
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		// timed out, cancel the remaining calls
+		slog.Warn("shutdown timed out", "error", err)
+		srv.Close()
+	}
+	wsWait(shutdownCtx)
+
+	err = api.Db.Close()
+	if err != nil {
+		slog.Error("cannot close database", "error", err)
+	}
 }
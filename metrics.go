@@ -0,0 +1,66 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var (
+	apiFRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "estate",
+			Name:      "api_requests_total",
+			Help:      "API function calls by function and response code.",
+		},
+		[]string{"function", "code"},
+	)
+	apiFDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "estate",
+			Name:      "api_request_duration_seconds",
+			Help:      "API function call latency.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"function"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(apiFRequests, apiFDuration)
+}
+
+// registerDBMetrics: export connection pool statistics of db
+func registerDBMetrics(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "estate"))
+}
+
+// codeLabel: metric label of a response code
+func codeLabel(code uint8) string {
+	if code == 0 {
+		return "OK"
+	}
+	if name, ok := api.ErrorNames[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
+
+// instrument: count calls of an API function and measure their latency
+func instrument(f *api.Function, handler api.RequestHandler) api.RequestHandler {
+	name := f.Name
+	duration := apiFDuration.WithLabelValues(name)
+	return func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		start := time.Now()
+		response, err := handler(ctx, db, r)
+		duration.Observe(time.Since(start).Seconds())
+		apiFRequests.WithLabelValues(name, codeLabel(api.ResponseCode(response))).Inc()
+		return response, err
+	}
+}
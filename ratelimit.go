@@ -0,0 +1,161 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"BastetSoftware/backend/ratelimit"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var rateStore ratelimit.Store = ratelimit.NewMemoryStore()
+
+// rateFallback: counters of this process, used while rateStore fails
+var rateFallback ratelimit.Store = ratelimit.NewMemoryStore()
+
+var (
+	loginIPLimit = ratelimit.Limit{N: 20, Window: time.Minute}  // authentication calls per client IP
+	loginLimit   = ratelimit.Limit{N: 5, Window: time.Minute}   // authentication calls per login
+	tokenLimit   = ratelimit.Limit{N: 600, Window: time.Minute} // other calls per session (per IP without a valid one)
+)
+
+// authFunctions: functions limited per client IP and per login
+var authFunctions = map[string]bool{
+	"user_log_in": true,
+	"user_create": true,
+}
+
+// trustedProxies: reverse proxies whose X-Forwarded-For header gives the client IP
+var trustedProxies []netip.Prefix
+
+type clientIPKey struct{}
+
+// withClientIP: store the client IP of r in ctx
+func withClientIP(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, requestIP(r))
+}
+
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// requestIP: address of the client of r; behind trusted proxies, the last
+// address of X-Forwarded-For that is not a trusted proxy
+func requestIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host) {
+		return host
+	}
+
+	// each proxy appends the address it received the request from
+	var forwarded []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(h, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(ip); err != nil {
+			break
+		}
+		host = ip
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return host
+}
+
+// trustedProxy: ip is in trustedProxies
+func trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyToken: token belongs to an open session; the result is kept in the
+// returned context, so that the function does not look the session up again
+func verifyToken(ctx context.Context, db database.Store, token string) (context.Context, bool) {
+	session, err := db.VerifySession(ctx, []byte(token))
+	switch err {
+	case nil:
+		return api.WithSession(ctx, token, session), true
+	case database.ErrNotLoggedIn:
+		return api.WithSession(ctx, token, nil), false
+	default:
+		return ctx, false
+	}
+}
+
+type rateCheck struct {
+	key   string
+	limit ratelimit.Limit
+}
+
+// rateLimit: reject calls over the limits with ETooManyRequests
+func rateLimit(f *api.Function, handler api.RequestHandler) api.RequestHandler {
+	auth := authFunctions[f.Name]
+	return func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+		// invalid arguments are reported by the handler
+		var args struct {
+			Login string
+			Token string
+		}
+		msgpack.Unmarshal(r, &args)
+
+		valid := false
+		if !auth && args.Token != "" {
+			ctx, valid = verifyToken(ctx, db, args.Token)
+		}
+
+		var checks []rateCheck
+		switch {
+		case auth:
+			checks = []rateCheck{
+				{"login-ip:" + clientIP(ctx), loginIPLimit},
+				{"login:" + args.Login, loginLimit},
+			}
+		case valid:
+			// do not keep tokens in the store
+			hash := sha256.Sum256([]byte(args.Token))
+			checks = []rateCheck{{"token:" + hex.EncodeToString(hash[:]), tokenLimit}}
+		default:
+			// made-up tokens share the limit of the client IP
+			checks = []rateCheck{{"ip:" + clientIP(ctx), tokenLimit}}
+		}
+
+		for _, c := range checks {
+			ok, err := ratelimit.Allow(ctx, rateStore, c.key, c.limit)
+			if err != nil {
+				// keep limiting, per process, while the shared store is unavailable
+				slog.WarnContext(ctx, "rate limit store failed", "request_id", requestID(ctx), "error", err)
+				ok, _ = ratelimit.Allow(ctx, rateFallback, c.key, c.limit)
+			}
+			if !ok {
+				return api.Response{Code: api.ETooManyRequests}, nil
+			}
+		}
+
+		return handler(ctx, db, r)
+	}
+}
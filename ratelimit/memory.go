@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memorySweepPeriod = time.Minute
+
+type memoryCounter struct {
+	start time.Time
+	end   time.Time
+	n     int64
+}
+
+// MemoryStore: in-process counters
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*memoryCounter)}
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, window time.Duration) (int64, error) {
+	now := clock()
+	start := windowStart(now, window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// drop counters of past windows
+	if now.Sub(s.lastSweep) > memorySweepPeriod {
+		for k, c := range s.counters {
+			if !now.Before(c.end) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !c.start.Equal(start) {
+		c = &memoryCounter{start: start, end: start.Add(window)}
+		s.counters[key] = c
+	}
+	c.n++
+
+	return c.n, nil
+}
@@ -0,0 +1,77 @@
+// Package ratelimit: fixed window rate limits with in-process or shared counters
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store: event counters per key and time window
+type Store interface {
+	// Incr: count an event of key and return the number of events of key
+	// in the current window
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+}
+
+// Limit: at most N events per Window, N = 0 means no limit
+type Limit struct {
+	N      int64
+	Window time.Duration
+}
+
+// ParseLimit: parse "N/duration", e.g. "10/1m"; "" and "0" mean no limit
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	n, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	var l Limit
+	var err error
+	l.N, err = strconv.ParseInt(n, 10, 64)
+	if err != nil || l.N < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+	l.Window, err = time.ParseDuration(window)
+	if err != nil || l.Window <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	return l, nil
+}
+
+// clock: current time, replaced by tests
+var clock = time.Now
+
+// windowStart: start of the window containing t
+func windowStart(t time.Time, window time.Duration) time.Time {
+	return t.Truncate(window)
+}
+
+// Allow: count an event of key, false if it exceeds the limit
+func Allow(ctx context.Context, store Store, key string, limit Limit) (bool, error) {
+	if limit.N == 0 {
+		return true, nil
+	}
+
+	n, err := store.Incr(ctx, key, limit.Window)
+	if err != nil {
+		return false, err
+	}
+
+	return n <= limit.N, nil
+}
+
+// UnmarshalText: parse a limit written as in ParseLimit
+func (l *Limit) UnmarshalText(text []byte) error {
+	var err error
+	*l, err = ParseLimit(string(text))
+	return err
+}
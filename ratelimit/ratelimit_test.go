@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"BastetSoftware/backend/database"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setClock: make the current time t until the test ends, returns a function
+// moving it forward
+func setClock(t *testing.T, now time.Time) func(d time.Duration) {
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = time.Now })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Limit
+		ok   bool
+	}{
+		{"", Limit{}, true},
+		{"0", Limit{}, true},
+		{"10/1m", Limit{N: 10, Window: time.Minute}, true},
+		{"600/1h30m", Limit{N: 600, Window: 90 * time.Minute}, true},
+		{"0/1m", Limit{N: 0, Window: time.Minute}, true},
+		{"10", Limit{}, false},
+		{"x/1m", Limit{}, false},
+		{"-1/1m", Limit{}, false},
+		{"10/x", Limit{}, false},
+		{"10/0s", Limit{}, false},
+		{"10/-1m", Limit{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseLimit(%q): got %+v, %v", tt.s, got, err)
+		}
+	}
+}
+
+// testStores: stores the tests run against
+var testStores = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store {
+		return NewMemoryStore()
+	},
+	"sqlite": func(t *testing.T) Store {
+		ctx := context.Background()
+		db, err := database.Open(database.Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "estate.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := db.(*database.SQLStore)
+		t.Cleanup(func() { s.Close() })
+		if _, err := s.MigrateUp(ctx); err != nil {
+			t.Fatal(err)
+		}
+		store, err := NewSQLStore(s.DB(), s.Driver())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	},
+}
+
+func TestAllow(t *testing.T) {
+	for _, name := range []string{"memory", "sqlite"} {
+		open := testStores[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			advance := setClock(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+			store := open(t)
+			limit := Limit{N: 2, Window: time.Minute}
+
+			allow := func(key string, want bool) {
+				t.Helper()
+				ok, err := Allow(ctx, store, key, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok != want {
+					t.Errorf("Allow(%q): got %v, want %v", key, ok, want)
+				}
+			}
+
+			allow("a", true)
+			allow("a", true)
+			allow("a", false)
+			allow("b", true)
+
+			// same window
+			advance(59 * time.Second)
+			allow("a", false)
+
+			// next window
+			advance(time.Second)
+			allow("a", true)
+			allow("a", true)
+			allow("a", false)
+			allow("b", true)
+			allow("b", true)
+			allow("b", false)
+
+			for i := 0; i < 10; i++ {
+				if ok, err := Allow(ctx, store, "c", Limit{}); !ok || err != nil {
+					t.Fatalf("Allow without a limit: got %v, %v", ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	advance := setClock(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	store := NewMemoryStore()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := store.Incr(ctx, key, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	advance(2 * memorySweepPeriod)
+	if _, err := store.Incr(ctx, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.counters) != 1 {
+		t.Errorf("counters after the sweep: got %d, want 1", len(store.counters))
+	}
+}
+
+func TestNewSQLStore(t *testing.T) {
+	if _, err := NewSQLStore(nil, "oracle"); err == nil {
+		t.Error("NewSQLStore of an unknown driver: no error")
+	}
+}
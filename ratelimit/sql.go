@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLStore: counters in the rate_limits table of the database,
+// shared by all instances using it
+type SQLStore struct {
+	db *sql.DB
+	q  sqlQueries
+}
+
+// sqlQueries: statements of an SQL dialect
+type sqlQueries struct {
+	upsert string // count a hit of name ($1) in the window starting at $2
+	count  string // hits of name ($1)
+}
+
+var dialects = map[string]sqlQueries{
+	// count is updated before window_start, so it sees the old window
+	"mysql": {
+		upsert: `INSERT INTO rate_limits (name, window_start, count) VALUES (?,?,1)
+		 ON DUPLICATE KEY UPDATE
+		     count = IF(window_start = VALUES(window_start), count + 1, 1),
+		     window_start = VALUES(window_start);`,
+		count: "SELECT count FROM rate_limits WHERE name=?;",
+	},
+	"postgres": {
+		upsert: `INSERT INTO rate_limits (name, window_start, count) VALUES ($1,$2,1)
+		 ON CONFLICT (name) DO UPDATE SET
+		     count = CASE WHEN rate_limits.window_start = excluded.window_start THEN rate_limits.count + 1 ELSE 1 END,
+		     window_start = excluded.window_start;`,
+		count: "SELECT count FROM rate_limits WHERE name=$1;",
+	},
+	"sqlite": {
+		upsert: `INSERT INTO rate_limits (name, window_start, count) VALUES (?,?,1)
+		 ON CONFLICT (name) DO UPDATE SET
+		     count = CASE WHEN rate_limits.window_start = excluded.window_start THEN rate_limits.count + 1 ELSE 1 END,
+		     window_start = excluded.window_start;`,
+		count: "SELECT count FROM rate_limits WHERE name=?;",
+	},
+}
+
+// NewSQLStore: store in db of the SQL dialect driver, "mysql", "postgres" or "sqlite"
+func NewSQLStore(db *sql.DB, driver string) (*SQLStore, error) {
+	q, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("rate limits: unsupported database driver %q", driver)
+	}
+	return &SQLStore{db: db, q: q}, nil
+}
+
+func (s *SQLStore) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	start := windowStart(clock(), window).Unix()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, s.q.upsert, key, start)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = tx.QueryRowContext(ctx, s.q.count, key).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/config"
+	"BastetSoftware/backend/database"
+	"BastetSoftware/backend/ratelimit"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRequestIP(t *testing.T) {
+	proxies, err := config.ParseNetworks([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved []netip.Prefix) { trustedProxies = saved }(trustedProxies)
+	trustedProxies = proxies
+
+	tests := []struct {
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"198.51.100.7:4000", nil, "198.51.100.7"},
+		// not from a trusted proxy: the header is ignored
+		{"198.51.100.7:4000", []string{"203.0.113.5"}, "198.51.100.7"},
+		{"10.1.2.3:4000", nil, "10.1.2.3"},
+		{"10.1.2.3:4000", []string{"203.0.113.5"}, "203.0.113.5"},
+		{"192.0.2.1:4000", []string{"203.0.113.5, 10.0.0.1"}, "203.0.113.5"},
+		{"10.1.2.3:4000", []string{"203.0.113.5", "10.0.0.1"}, "203.0.113.5"},
+		// addresses added by the client before the first trusted proxy are not used
+		{"10.1.2.3:4000", []string{"1.1.1.1, 203.0.113.5"}, "203.0.113.5"},
+		{"10.1.2.3:4000", []string{"10.0.0.2, 10.0.0.1"}, "10.0.0.2"},
+		{"10.1.2.3:4000", []string{"junk, 10.0.0.1"}, "10.0.0.1"},
+		{"[::ffff:10.1.2.3]:4000", []string{"203.0.113.5"}, "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/ping", nil)
+		r.RemoteAddr = tt.remote
+		for _, h := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", h)
+		}
+		if got := requestIP(r); got != tt.want {
+			t.Errorf("%s, X-Forwarded-For %q: got %s, want %s", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
+
+// countingStore: store counting session lookups
+type countingStore struct {
+	database.Store
+	lookups int
+}
+
+func (s *countingStore) VerifySession(ctx context.Context, token []byte) (*database.Session, error) {
+	s.lookups++
+	return s.Store.VerifySession(ctx, token)
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	mem := database.NewMemoryStore()
+	uid, err := mem.RegisterUser(ctx, &database.UserInfo{Login: "ivanov", PassHash: []byte("hash"), FirstName: "Иван", LastName: "Иванов"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mem.AddSession(ctx, &database.Session{Token: []byte("token"), ExpiryDate: time.Now().Add(time.Hour).Unix(), User: uid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &countingStore{Store: mem}
+
+	defer func(store ratelimit.Store, limit ratelimit.Limit) { rateStore, tokenLimit = store, limit }(rateStore, tokenLimit)
+	rateStore = ratelimit.NewMemoryStore()
+	tokenLimit = ratelimit.Limit{N: 2, Window: time.Hour}
+
+	f := api.V2.Lookup("user_get_info")
+	handler := rateLimit(f, f.Handler)
+	r := httptest.NewRequest(http.MethodPost, "/api/user_get_info", nil)
+	r.RemoteAddr = "198.51.100.7:4000"
+	ctx = api.WithVersion(withClientIP(ctx, r), api.V2)
+
+	call := func(token string) uint8 {
+		t.Helper()
+		args, err := msgpack.Marshal(api.ArgsFUserInfo{Token: token, Login: "ivanov"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.lookups = 0
+		resp, _ := handler(ctx, db, args)
+		b, err := msgpack.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var code struct{ Code uint8 }
+		if err = msgpack.Unmarshal(b, &code); err != nil {
+			t.Fatal(err)
+		}
+		return code.Code
+	}
+
+	// the session is looked up once, by the rate limit
+	if code := call("token"); code != 0 || db.lookups != 1 {
+		t.Errorf("valid token: code %d, %d session lookups", code, db.lookups)
+	}
+	if code := call("wrong"); code != api.ENotLoggedIn || db.lookups != 1 {
+		t.Errorf("wrong token: code %d, %d session lookups", code, db.lookups)
+	}
+
+	// the session and the client IP have limits of their own
+	if code := call("token"); code != 0 {
+		t.Errorf("second call with the token: code %d", code)
+	}
+	if code := call("token"); code != api.ETooManyRequests {
+		t.Errorf("third call with the token: code %d, want ETooManyRequests", code)
+	}
+	if code := call("other"); code != api.ENotLoggedIn {
+		t.Errorf("second call with a wrong token: code %d", code)
+	}
+	if code := call("another"); code != api.ETooManyRequests {
+		t.Errorf("third call with a wrong token: code %d, want ETooManyRequests", code)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// recoverPanic: turn a panic of the handler into an EUnknown response
+func recoverPanic(f *api.Function, handler api.RequestHandler) api.RequestHandler {
+	name := f.Name
+	return func(ctx context.Context, db database.Store, r []byte) (response interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				slog.ErrorContext(ctx, "panic", "request_id", requestID(ctx), "function", name,
+					"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+				response = api.Response{Code: api.EUnknown}
+				err = fmt.Errorf("%s: panic: %v", name, p)
+			}
+		}()
+
+		return handler(ctx, db, r)
+	}
+}
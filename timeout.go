@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+var requestTimeout = 30 * time.Second     // API function timeout, 0 for none
+var apiFTimeouts map[string]time.Duration // per-function timeouts, override requestTimeout
+
+// handlerContext: context of an API function call, cancelled when
+// the parent is done or the function timeout expires
+func handlerContext(parent context.Context, name string) (context.Context, context.CancelFunc) {
+	timeout, ok := apiFTimeouts[name]
+	if !ok {
+		timeout = requestTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
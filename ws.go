@@ -0,0 +1,274 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	wsMaxInFlight = 16 // requests handled concurrently per connection
+	wsPongWait    = 60 * time.Second
+	wsPingPeriod  = wsPongWait * 9 / 10
+	wsWriteWait   = 10 * time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		o := r.Header.Get("Origin")
+		return o == "" || cors.allowOrigin(o)
+	},
+}
+
+var wsShutdown = make(chan struct{}) // closed when the server shuts down
+var wsConns sync.WaitGroup           // open connections
+
+// wsClose: ask all connections to close, called on server shutdown
+func wsClose() {
+	close(wsShutdown)
+}
+
+// wsWait: wait until all connections are closed or ctx is done
+func wsWait(ctx context.Context) {
+	closed := make(chan struct{})
+	go func() {
+		wsConns.Wait()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+	}
+}
+
+// wsConn: WebSocket connection with serialized writes
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsConn) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// handle: run one request frame and send the response back.
+// Binary messages carry msgpack, text messages carry JSON.
+func (c *wsConn) handle(ctx context.Context, messageType int, data []byte) {
+	frameCodec := codecMsgpack
+	if messageType == websocket.TextMessage {
+		frameCodec = codecJSON
+	}
+
+	var resp api.FrameResp
+	var frame api.Frame
+	args, err := frameCodec.decodeArgs(data)
+	if err == nil {
+		err = api.CustomUnmarshal(args, &frame)
+	}
+	if err != nil {
+		resp.Resp = api.Response{Code: api.EArgsInval}
+		slog.DebugContext(ctx, "malformed frame", "request_id", requestID(ctx), "error", err)
+	} else {
+		resp.Id = frame.Id
+		if f := api.VersionOf(ctx).LookupF(frame.Func); f != nil {
+			// calls of a connection: connection id and frame id
+			ctx = withRequestID(ctx, requestID(ctx)+"."+strconv.FormatUint(uint64(frame.Id), 10))
+			ctx, cancel := handlerContext(ctx, f.Name)
+			resp.Resp, _ = f.Handler(ctx, api.Db, frame.Args) // logged by logCall
+			cancel()
+		} else {
+			resp.Resp = api.Response{Code: api.ENoFun}
+		}
+	}
+
+	c.reply(ctx, messageType, resp)
+}
+
+// reply: send resp in a message of messageType
+func (c *wsConn) reply(ctx context.Context, messageType int, resp api.FrameResp) {
+	frameCodec := codecMsgpack
+	if messageType == websocket.TextMessage {
+		frameCodec = codecJSON
+	}
+
+	resp.Resp = api.WithErrorDetail(resp.Resp)
+	out, err := frameCodec.encode(resp)
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot encode response", "request_id", requestID(ctx), "error", err)
+		return
+	}
+
+	err = c.write(messageType, out)
+	if err != nil {
+		slog.DebugContext(ctx, "cannot write response", "request_id", requestID(ctx), "error", err)
+	}
+}
+
+// frameID: Id of a frame read from its beginning, 0 if it is not there
+func frameID(messageType int, data []byte) uint32 {
+	if messageType == websocket.TextMessage {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+			return 0
+		}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return 0
+			}
+			if key == "Id" {
+				var id uint32
+				dec.Decode(&id)
+				return id
+			}
+			var value json.RawMessage
+			if dec.Decode(&value) != nil {
+				return 0
+			}
+		}
+		return 0
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return 0
+	}
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return 0
+		}
+		if key == "Id" {
+			id, _ := dec.DecodeUint32()
+			return id
+		}
+		if dec.Skip() != nil {
+			return 0
+		}
+	}
+	return 0
+}
+
+// readMessage: next message of conn, at most maxBodySize bytes of it;
+// tooLarge is set if the message is longer
+func readMessage(conn *websocket.Conn) (messageType int, data []byte, tooLarge bool, err error) {
+	messageType, r, err := conn.NextReader()
+	if err != nil {
+		return 0, nil, false, err
+	}
+	// the rest of a long message is discarded by the next call
+	data, err = io.ReadAll(io.LimitReader(r, maxBodySize+1))
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if int64(len(data)) > maxBodySize {
+		return messageType, data, true, nil
+	}
+	return messageType, data, false, nil
+}
+
+// wsHandler: persistent transport of the functions of v, requests are
+// api.Frame messages with numeric function dispatch (see api.Version)
+func wsHandler(v *api.Version) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := make(http.Header)
+		header.Set(requestIDHeader, requestID(r.Context()))
+		versionHeaders(header, v)
+		wsServe(w, r, v, header)
+	}
+}
+
+func wsServe(w http.ResponseWriter, r *http.Request, v *api.Version, header http.Header) {
+	conn, err := wsUpgrader.Upgrade(w, r, header)
+	if err != nil {
+		slog.DebugContext(r.Context(), "cannot upgrade", "request_id", requestID(r.Context()), "error", err)
+		return
+	}
+	defer conn.Close()
+	wsConns.Add(1)
+	defer wsConns.Done()
+
+	c := &wsConn{conn: conn}
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	// keep the connection alive, close it on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-wsShutdown:
+				// stop reading, requests in flight are still answered
+				msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+				c.write(websocket.CloseMessage, msg)
+				conn.SetReadDeadline(time.Now())
+				return
+			case <-ticker.C:
+				if err := c.write(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup // requests in flight
+	ctx, cancel := context.WithCancel(api.WithVersion(withClientIP(r.Context(), r), v))
+	defer cancel()
+
+	inFlight := make(chan struct{}, wsMaxInFlight)
+	for {
+		messageType, data, tooLarge, err := readMessage(conn)
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.InfoContext(ctx, "connection lost", "request_id", requestID(ctx), "error", err)
+			}
+			select {
+			case <-wsShutdown:
+				// reading stopped by the shutdown, requests in flight are answered
+			default:
+				// the client is gone, nobody waits for the requests in flight
+				cancel()
+			}
+			wg.Wait()
+			return
+		}
+		if messageType != websocket.BinaryMessage && messageType != websocket.TextMessage {
+			continue
+		}
+		if tooLarge {
+			c.reply(ctx, messageType, api.FrameResp{Id: frameID(messageType, data), Resp: tooLargeResponse()})
+			continue
+		}
+
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-inFlight; wg.Done() }()
+			c.handle(ctx, messageType, data)
+		}()
+	}
+}
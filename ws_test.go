@@ -0,0 +1,50 @@
+package main
+
+import (
+	"BastetSoftware/backend/api"
+	"BastetSoftware/backend/database"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWsDisconnectCancels(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	v := &api.Version{Name: "test", Functions: []api.Function{{
+		Name: "block",
+		Handler: func(ctx context.Context, db database.Store, r []byte) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return api.Response{Code: api.EUnknown}, ctx.Err()
+		},
+	}}}
+
+	srv := httptest.NewServer(wsHandler(v))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(`{"Id": 1, "Func": 0}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not called")
+	}
+	conn.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler context not cancelled after the client disconnected")
+	}
+}